@@ -1,8 +1,16 @@
 package signedpost
 
 import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
 	"github.com/ethanfrey/signedpost/redux"
+	"github.com/ethanfrey/signedpost/view"
 	"github.com/ethanfrey/tenderize/sign"
+	dbm "github.com/tendermint/go-db"
 	merkle "github.com/tendermint/go-merkle"
 	tmsp "github.com/tendermint/tmsp/types"
 )
@@ -11,20 +19,130 @@ import (
 type Application struct {
 	commited *redux.Service
 	check    *redux.Service
+	db       dbm.DB       // nil if we only keep state in memory
+	last     CommitState  // the last block we committed
+	height   uint64       // the block we are currently processing
+	inBlock  bool         // true between BeginBlock and EndBlock
+	snapshot atomic.Value // view.Snapshot of the last commit, for all queries
+	history  *history     // snapshots of past commits
+	trusted  view.TrustedAttesters
+	pending  []txEvent   // successful txs of the current block
+	blockTxs [][]byte    // all txs of the current block, to redo them at another height
+	stream   *stream     // pushes the events of every commit to /stream
+	txs      *txIndex    // status of the last txs, for /tndr/tx/{hash}
+	times    *blockTimes // when each block was made, to render (not in the consensus state)
+	feeds    *feedCache  // the global atom feed of the last state we rendered it from
 }
 
 // NewApp creates a new tmsp application
 func NewApp(tree merkle.Tree) *Application {
 	a := Application{
 		commited: redux.New(tree, 0),
+		history:  newHistory(defaultHistory, nil),
+		stream:   newStream(1),
+		txs:      newTxIndex(),
+		times:    newBlockTimes(nil),
+		feeds:    newFeedCache(),
 	}
 	a.check = a.commited.Copy()
+	a.takeSnapshot()
 	return &a
 }
 
-// Info is a placeholder
+// LoadApp creates a tmsp application that persists to the given db.
+// It resumes from the last committed root if there is one.
+func LoadApp(db dbm.DB, cacheSize int) (*Application, error) {
+	state, err := LoadCommitState(db)
+	if err != nil {
+		return nil, errors.Wrap(err, "Loading commit state")
+	}
+	tree := merkle.NewIAVLTree(cacheSize, db)
+	tree.Load(state.Hash)
+
+	a := NewApp(tree)
+	a.db = db
+	a.history = newHistory(defaultHistory, db)
+	a.times = newBlockTimes(db)
+	a.stream = newStream(state.Height + 1)
+	a.last = state
+	a.height = state.Height
+	a.takeSnapshot()
+	return a, nil
+}
+
+// Snapshot returns a read-only view of the last committed state.
+// This is safe to call from any goroutine
+func (app *Application) Snapshot() view.Snapshot {
+	return app.snapshot.Load().(view.Snapshot)
+}
+
+// SnapshotAt returns a read-only view of the state after the block at this height
+// was committed. 0 means the latest. Only the last few heights are available (see KeepHistory)
+func (app *Application) SnapshotAt(height uint64) (view.Snapshot, error) {
+	snap := app.Snapshot()
+	if height == 0 || height == snap.Height {
+		return snap, nil
+	}
+	past, err := app.history.get(height, snap.Height)
+	past.Times = app.times
+	return past, err
+}
+
+// KeepHistory sets how many past commits can be queried.
+// Call this before serving any queries
+func (app *Application) KeepHistory(commits uint64) {
+	app.history.keep = commits
+}
+
+// FetchBlockTimes makes the app ask tendermint (through the proxy) for the time of blocks,
+// to show when posts were published. Without it, we use the time we committed each block.
+// Call this before serving any queries
+func (app *Application) FetchBlockTimes(p Proxy) {
+	app.times.chain = p.blockTime
+}
+
+// TrustAttesters sets the accounts (by id, as we render them) whose attestations this server trusts.
+// Call this before serving any queries
+func (app *Application) TrustAttesters(ids [][]byte) error {
+	trusted, err := view.NewTrustedAttesters(ids)
+	if err != nil {
+		return err
+	}
+	app.trusted = trusted
+	return nil
+}
+
+// takeSnapshot makes a read-only copy of the tree as of the last commit, for all queries
+func (app *Application) takeSnapshot() view.Snapshot {
+	snap := app.newSnapshot(app.last)
+	app.snapshot.Store(snap)
+	return snap
+}
+
+// newSnapshot makes a read-only copy of the tree, which was just committed with this state
+func (app *Application) newSnapshot(state CommitState) view.Snapshot {
+	return view.Snapshot{
+		Tree:   app.commited.GetDB().Copy(),
+		Height: state.Height,
+		Hash:   state.Hash,
+		Time:   time.Now(),
+		Times:  app.times,
+	}
+}
+
+// Info returns the last committed height and app hash as json
 func (app *Application) Info() string {
-	return app.commited.Info()
+	info := app.last.Info(app.commited.GetDB().Size())
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// LastCommit returns the height and hash of the last committed block
+func (app *Application) LastCommit() CommitState {
+	return app.last
 }
 
 // SetOption is ignored for now
@@ -34,35 +152,77 @@ func (app *Application) SetOption(key, value string) string {
 
 // AppendTx actually does something
 func (app *Application) AppendTx(tx []byte) tmsp.Result {
+	if !app.inBlock {
+		// tendermint up to v0.7 never calls BeginBlock, the block must follow our last commit
+		app.BeginBlock(app.last.Height + 1)
+	}
+	app.blockTxs = append(app.blockTxs, tx)
 	action, err := sign.Receive(tx)
 	if err != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, err.Error())
+		res := tmsp.NewError(redux.CodeInvalidTx, err.Error())
+		app.txs.append(tx, app.height, res)
+		return res
 	}
-	return app.commited.Apply(action)
+	res := app.commited.Apply(action)
+	if res.IsOK() {
+		app.pending = append(app.pending, newTxEvent(app.commited.GetDB(), action, res.Data))
+	}
+	app.txs.append(tx, app.height, res)
+	return res
 }
 
 // CheckTx validates a tx for the mempool
 func (app *Application) CheckTx(tx []byte) tmsp.Result {
 	action, err := sign.Receive(tx)
 	if err != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, err.Error())
+		res := tmsp.NewError(redux.CodeInvalidTx, err.Error())
+		app.txs.check(tx, res)
+		return res
 	}
-	return app.check.Apply(action)
+	res := app.check.Apply(action)
+	app.txs.check(tx, res)
+	return res
 }
 
-// Query returns contents behind given key
+// Query returns contents behind given key, as of the last commit
 func (app *Application) Query(query []byte) tmsp.Result {
-	_, val, exists := app.commited.GetDB().Get(query)
+	_, val, exists := app.Snapshot().Tree.Get(query)
 	if !exists {
 		return tmsp.NewError(tmsp.CodeType_BaseUnknownAddress, "")
 	}
 	return tmsp.NewResultOK(val, "")
 }
 
-// Commit returns the application Merkle root hash
+// Commit returns the application Merkle root hash.
+// If we have a db, this also persists the tree and the commit state
 func (app *Application) Commit() tmsp.Result {
+	var hash []byte
+	if app.db != nil {
+		hash = app.commited.Save()
+	} else {
+		hash = app.commited.Hash()
+	}
+	state := CommitState{Height: app.height, Hash: hash}
+
+	// record it in the history first, so a failure leaves the last commit state untouched
+	snap := app.newSnapshot(state)
+	app.times.observe(state.Height, snap.Time)
+	err := app.history.add(snap)
+	if err != nil {
+		return tmsp.NewError(tmsp.CodeType_InternalError, err.Error())
+	}
+	if app.db != nil {
+		err = state.Save(app.db)
+		if err != nil {
+			return tmsp.NewError(tmsp.CodeType_InternalError, err.Error())
+		}
+	}
+	app.last = state
 	app.check = app.commited.Copy()
-	hash := app.commited.Hash()
+	app.check.SetBlock(app.last.Height + 1)
+	app.snapshot.Store(snap)
+	app.txs.commit(snap.Height)
+	app.stream.publish(app.renderEvents(snap))
 	return tmsp.NewResultOK(hash, "")
 }
 
@@ -70,14 +230,39 @@ func (app *Application) Commit() tmsp.Result {
 // (but we ignore all but BeginBlock)
 func (app *Application) InitChain(validators []*tmsp.Validator) {}
 
-// BeginBlock signals the beginning of a block, update service so we tag posts properly
+// BeginBlock signals the beginning of a block, update service so we tag posts properly.
+// This version of TMSP only passes us the height, not the header, so we store no block time:
+// we must not look it up anywhere else, or nodes could disagree on the app hash.
 func (app *Application) BeginBlock(height uint64) {
-	// TODO: this is never called in the current code, so we make do with EndBlock, implying a begin block
+	app.height = height
+	app.inBlock = true
+	app.blockTxs = nil
+	app.commited.SetBlock(height)
 }
 
-// EndBlock signals the end of a block, ignored now
+// EndBlock signals the end of a block, so we know which height we commit
 // diffs: changed validators from app to TendermintCore
+//
+// Tendermint never calls BeginBlock, so we tagged the txs of this block with the height
+// after our last commit. If that is not the height of the chain, we missed blocks
+// (sp-server replays them on startup, so this should not happen), and we apply the txs
+// of this block again with the right height, rather than stop the chain
 func (app *Application) EndBlock(height uint64) (diffs []*tmsp.Validator) {
-	app.commited.SetHeight(height + 1)
+	if height != app.height {
+		app.redoBlock(height)
+	}
+	app.inBlock = false
 	return nil
 }
+
+// redoBlock applies all txs of the current block again, on top of our last commit,
+// as part of the block at this height
+func (app *Application) redoBlock(height uint64) {
+	txs := app.blockTxs
+	app.commited = redux.New(app.Snapshot().Tree.Copy(), app.last.Height)
+	app.pending = nil
+	app.BeginBlock(height)
+	for _, tx := range txs {
+		app.AppendTx(tx)
+	}
+}
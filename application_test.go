@@ -1,16 +1,35 @@
 package signedpost
 
 import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethanfrey/signedpost/redux"
 	"github.com/ethanfrey/signedpost/store"
 	"github.com/ethanfrey/signedpost/txn"
+	"github.com/ethanfrey/signedpost/utils"
+	"github.com/ethanfrey/signedpost/view"
+	"github.com/ethanfrey/tenderize/client"
 	"github.com/ethanfrey/tenderize/mom"
 	"github.com/ethanfrey/tenderize/sign"
+	wutil "github.com/ethanfrey/tenderize/wire"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	crypto "github.com/tendermint/go-crypto"
+	dbm "github.com/tendermint/go-db"
 	merkle "github.com/tendermint/go-merkle"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+	tmsp "github.com/tendermint/tmsp/types"
 )
 
 func TestApplication(t *testing.T) {
@@ -22,8 +41,7 @@ func TestApplication(t *testing.T) {
 	// make sure initial hash is nil
 	assert.Nil(app.Commit().Data)
 
-	// TODO: use BeginBlock(2), when that works...
-	app.EndBlock(1)
+	app.BeginBlock(1)
 	utx := txn.CreateAccountAction{Name: "Grey"}
 	data, err := sign.Send(utx, earl)
 	require.Nil(err, "%+v", err)
@@ -48,8 +66,9 @@ func TestApplication(t *testing.T) {
 
 	// now add the post
 	ptx := txn.AddPostAction{
-		Title:   "Good post",
-		Content: "Some imporant info",
+		Title:    "Good post",
+		Content:  "Some imporant info",
+		Sequence: 1,
 	}
 	pdata, err := sign.Send(ptx, earl)
 	require.Nil(err, "%+v", err)
@@ -62,5 +81,1027 @@ func TestApplication(t *testing.T) {
 	// now, really append
 	pres = app.AppendTx(pdata)
 	assert.False(pres.IsErr(), pres.Error())
-	assert.NotEqual(hash, app.Commit().Data)
+	hash2 := app.Commit().Data
+	assert.NotEqual(hash, hash2)
+
+	// replaying the same tx is rejected, in check and append
+	pres = app.CheckTx(pdata)
+	assert.Equal(redux.CodeBadSequence, pres.Code)
+	pres = app.AppendTx(pdata)
+	assert.Equal(redux.CodeBadSequence, pres.Code)
+	assert.Equal(hash2, app.Commit().Data)
+}
+
+func TestPersistence(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	earl := crypto.GenPrivKeyEd25519()
+	db := dbm.NewMemDB()
+
+	app, err := LoadApp(db, 0)
+	require.Nil(err, "%+v", err)
+	assert.Nil(app.Commit().Data)
+
+	app.BeginBlock(1)
+	data, err := sign.Send(txn.CreateAccountAction{Name: "Persist"}, earl)
+	require.Nil(err, "%+v", err)
+	ures := app.AppendTx(data)
+	require.False(ures.IsErr(), ures.Error())
+	ukey := ures.Data
+	app.EndBlock(1)
+	hash := app.Commit().Data
+	require.NotNil(hash)
+
+	// a restarted app must resume from the same root
+	app2, err := LoadApp(db, 0)
+	require.Nil(err, "%+v", err)
+	assert.Equal(CommitState{Height: 1, Hash: hash}, app2.last)
+	qres := app2.Query(ukey)
+	assert.False(qres.IsErr(), qres.Error())
+
+	// and keep on committing from there (even if BeginBlock is not called)
+	pdata, err := sign.Send(txn.AddPostAction{Title: "Again", Content: "After restart", Sequence: 1}, earl)
+	require.Nil(err, "%+v", err)
+	pres := app2.AppendTx(pdata)
+	assert.False(pres.IsErr(), pres.Error())
+	app2.EndBlock(2)
+	assert.NotEqual(hash, app2.Commit().Data)
+	post, err := view.PostByKey(app2.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(2, post.PublishedBlock)
+	// without a chain to ask, this is when we committed it
+	assert.NotEmpty(post.PublishedTime)
+
+	// after a gap in the chain, the txs are redone with the height of the chain
+	edata, err := sign.Send(txn.EditPostAction{Number: post.Number, Title: "Later", Content: "After a gap", Sequence: 2}, earl)
+	require.Nil(err, "%+v", err)
+	eres := app2.AppendTx(edata)
+	require.False(eres.IsErr(), eres.Error())
+	assert.NotPanics(func() { app2.EndBlock(4) })
+	assert.False(app2.Commit().IsErr())
+	assert.EqualValues(4, app2.LastCommit().Height)
+	post, err = view.PostByKey(app2.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(4, post.EditedBlock)
+	assert.EqualValues(1, post.Revisions)
+}
+
+// memBlocks is a BlockStore for replay tests
+type memBlocks []*types.Block
+
+func (m memBlocks) Height() int {
+	return len(m)
+}
+
+func (m memBlocks) LoadBlock(height int) *types.Block {
+	return m[height-1]
+}
+
+func makeBlock(apphash []byte, txs ...[]byte) *types.Block {
+	block := &types.Block{
+		Header: &types.Header{AppHash: apphash},
+		Data:   &types.Data{},
+	}
+	for _, tx := range txs {
+		block.Txs = append(block.Txs, tx)
+	}
+	return block
+}
+
+func TestBlockTimes(t *testing.T) {
+	assert := assert.New(t)
+	db := dbm.NewMemDB()
+	header := time.Date(2016, 11, 16, 12, 0, 0, 0, time.UTC)
+	seen := header.Add(time.Second)
+
+	// we prefer the header time over the time we committed the block
+	times := newBlockTimes(db)
+	assert.True(times.BlockTime(1).IsZero())
+	times.observe(1, seen)
+	assert.Equal(seen, times.BlockTime(1))
+	times.header(1, header)
+	assert.Equal(header, times.BlockTime(1))
+
+	// and ask the chain for the header if we can, but only once
+	asked := 0
+	times.chain = func(height uint64) (time.Time, error) {
+		asked++
+		return header.Add(time.Duration(height) * time.Second), nil
+	}
+	times.observe(2, seen)
+	assert.Equal(header.Add(2*time.Second), times.BlockTime(2))
+	assert.Equal(header.Add(2*time.Second), times.BlockTime(2))
+	assert.Equal(1, asked)
+
+	// if the chain does not answer, we use the time we committed it
+	times.chain = func(height uint64) (time.Time, error) {
+		return time.Time{}, errors.New("No connection")
+	}
+	times.observe(3, seen)
+	assert.Equal(seen, times.BlockTime(3))
+
+	// all of this is stored in the db
+	times = newBlockTimes(db)
+	assert.Equal(header, times.BlockTime(1))
+	assert.Equal(header.Add(2*time.Second), times.BlockTime(2))
+	assert.Equal(seen, times.BlockTime(3))
+	assert.True(times.BlockTime(4).IsZero())
+}
+
+func TestReplay(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	earl := crypto.GenPrivKeyEd25519()
+	utx, err := sign.Send(txn.CreateAccountAction{Name: "Replay"}, earl)
+	require.Nil(err, "%+v", err)
+	ptx, err := sign.Send(txn.AddPostAction{Title: "Replayed", Content: "Again", Sequence: 1}, earl)
+	require.Nil(err, "%+v", err)
+
+	// run the chain once live, to learn the app hashes
+	live := NewApp(merkle.NewIAVLTree(0, nil))
+	live.AppendTx(utx)
+	live.EndBlock(1)
+	h1 := live.Commit().Data
+	pres := live.AppendTx(ptx)
+	live.EndBlock(2)
+	h2 := live.Commit().Data
+
+	blocks := memBlocks{
+		makeBlock(nil, utx),
+		makeBlock(h1, ptx),
+		makeBlock(h2),
+	}
+	blocks[1].Time = time.Date(2016, 11, 16, 12, 0, 0, 0, time.UTC)
+
+	// an app with a stale db catches up
+	db := dbm.NewMemDB()
+	app, err := LoadApp(db, 0)
+	require.Nil(err, "%+v", err)
+	err = app.Replay(blocks[:2])
+	require.Nil(err, "%+v", err)
+	assert.Equal(CommitState{Height: 2, Hash: h2}, app.LastCommit())
+	assert.Contains(app.Info(), `"height":2`)
+
+	// after a restart, only replay the missing block
+	app, err = LoadApp(db, 0)
+	require.Nil(err, "%+v", err)
+	err = app.Replay(blocks)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(3, app.LastCommit().Height)
+	assert.Equal(h2, app.LastCommit().Hash)
+
+	// posts show the time in the header of their block, which we remember across restarts
+	post, err := view.PostByKey(app.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	assert.Equal("2016-11-16T12:00:00Z", post.PublishedTime)
+
+	// refuse to replay a different chain
+	fork := memBlocks{makeBlock(nil, ptx), makeBlock(h1)}
+	app = NewApp(merkle.NewIAVLTree(0, nil))
+	err = app.Replay(fork)
+	assert.NotNil(err)
+}
+
+func TestProofs(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	earl := crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	utx, err := sign.Send(txn.CreateAccountAction{Name: "Prover"}, earl)
+	require.Nil(err, "%+v", err)
+	ptx, err := sign.Send(txn.AddPostAction{Title: "Proven", Content: "Existed at this time", Sequence: 1}, earl)
+	require.Nil(err, "%+v", err)
+	app.AppendTx(utx)
+	pres := app.AppendTx(ptx)
+	require.False(pres.IsErr(), pres.Error())
+	app.EndBlock(1)
+	hash := app.Commit().Data
+
+	proof, err := view.PostProof(app.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(1, proof.Height)
+	assert.Equal(hex.EncodeToString(hash), proof.RootHash)
+	assert.Equal(hex.EncodeToString(pres.Data), proof.Key)
+
+	// anyone can check this against the app hash
+	value, err := hex.DecodeString(proof.Value)
+	require.Nil(err)
+	pbytes, err := hex.DecodeString(proof.Proof)
+	require.Nil(err)
+	iavl := merkle.IAVLProof{}
+	err = wutil.FromBinary(pbytes, &iavl)
+	require.Nil(err, "%+v", err)
+	assert.True(iavl.Verify(pres.Data, value, hash))
+	model, err := mom.ModelFromBytes(value)
+	require.Nil(err, "%+v", err)
+	post, ok := model.(store.Post)
+	if assert.True(ok) {
+		assert.Equal("Proven", post.Title)
+	}
+	first := proof.Value
+
+	// after a retraction, the original content can still be proven
+	rtx, err := sign.Send(txn.RetractPostAction{Number: 1, Reason: "Superseded", Sequence: 2}, earl)
+	require.Nil(err, "%+v", err)
+	rres := app.AppendTx(rtx)
+	require.False(rres.IsErr(), rres.Error())
+	app.EndBlock(2)
+	hash = app.Commit().Data
+	proof, err = view.PostProof(app.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	value, err = hex.DecodeString(proof.Value)
+	require.Nil(err)
+	pbytes, err = hex.DecodeString(proof.Proof)
+	require.Nil(err)
+	iavl = merkle.IAVLProof{}
+	err = wutil.FromBinary(pbytes, &iavl)
+	require.Nil(err, "%+v", err)
+	assert.True(iavl.Verify(pres.Data, value, hash))
+	model, err = mom.ModelFromBytes(value)
+	require.Nil(err, "%+v", err)
+	post, ok = model.(store.Post)
+	if assert.True(ok) {
+		assert.Equal("Proven", post.Title)
+	}
+	// the post itself is not touched, only the rendered one is flagged
+	assert.Equal(first, proof.Value)
+	rendered, err := view.PostByKey(app.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	assert.True(rendered.Retracted)
+	assert.Equal("Superseded", rendered.RetractReason)
+
+	// missing keys have no proof
+	_, err = view.AccountProof(app.Snapshot(), []byte("12345678901234567890"))
+	assert.NotNil(err)
+}
+
+func TestSnapshot(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	earl := crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	utx, err := sign.Send(txn.CreateAccountAction{Name: "Snap"}, earl)
+	require.Nil(err, "%+v", err)
+	app.EndBlock(1)
+	app.Commit()
+	snap := app.Snapshot()
+	assert.EqualValues(1, snap.Height)
+
+	// queries don't see the account until the block is committed
+	ures := app.AppendTx(utx)
+	require.False(ures.IsErr(), ures.Error())
+	accts, err := view.AllAccounts(app.Snapshot(), store.Page{})
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(0, accts.Count)
+	assert.EqualValues(1, accts.Height)
+	assert.True(app.Query(ures.Data).IsErr())
+
+	app.EndBlock(2)
+	hash := app.Commit().Data
+	accts, err = view.AllAccounts(app.Snapshot(), store.Page{})
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(1, accts.Count)
+	assert.EqualValues(2, accts.Height)
+	assert.Equal(hash, app.Snapshot().Hash)
+
+	// and old snapshots are never modified
+	accts, err = view.AllAccounts(snap, store.Page{})
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(0, accts.Count)
+}
+
+func TestHistory(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	earl := crypto.GenPrivKeyEd25519()
+	db := dbm.NewMemDB()
+	app, err := LoadApp(db, 0)
+	require.Nil(err, "%+v", err)
+	app.KeepHistory(3)
+
+	// one block with an account, then one post per block
+	utx, err := sign.Send(txn.CreateAccountAction{Name: "Historian"}, earl)
+	require.Nil(err, "%+v", err)
+	app.AppendTx(utx)
+	app.EndBlock(1)
+	app.Commit()
+	addr := earl.PubKey().Address()
+	for i := 1; i <= 4; i++ {
+		ptx, err := sign.Send(txn.AddPostAction{Title: "Post", Content: "Entry", Sequence: int64(i)}, earl)
+		require.Nil(err, "%+v", err)
+		pres := app.AppendTx(ptx)
+		require.False(pres.IsErr(), pres.Error())
+		app.EndBlock(uint64(i + 1))
+		app.Commit()
+	}
+
+	// latest by default
+	snap, err := app.SnapshotAt(0)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(5, snap.Height)
+	acct, err := view.AccountByKey(snap, accountID(addr), nil)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(4, acct.PostCount)
+	assert.EqualValues(5, acct.Height)
+
+	// the state as of a past block
+	snap, err = app.SnapshotAt(3)
+	require.Nil(err, "%+v", err)
+	acct, err = view.AccountByKey(snap, accountID(addr), nil)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(2, acct.PostCount)
+	assert.EqualValues(3, acct.Height)
+
+	// out of the window, or the future
+	_, err = app.SnapshotAt(2)
+	assert.NotNil(err)
+	_, err = app.SnapshotAt(6)
+	assert.NotNil(err)
+
+	// the window is still there after a restart
+	app, err = LoadApp(db, 0)
+	require.Nil(err, "%+v", err)
+	app.KeepHistory(3)
+	snap, err = app.SnapshotAt(4)
+	require.Nil(err, "%+v", err)
+	acct, err = view.AccountByKey(snap, accountID(addr), nil)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(3, acct.PostCount)
+	_, err = app.SnapshotAt(2)
+	assert.NotNil(err)
+}
+
+func TestAccountIDs(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	earl := crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	utx, err := sign.Send(txn.CreateAccountAction{Name: "Ident"}, earl)
+	require.Nil(err, "%+v", err)
+	ukey := app.AppendTx(utx).Data
+	app.EndBlock(1)
+	app.Commit()
+
+	// the id returned by the tx is the one we render
+	acct, err := view.AccountByKey(app.Snapshot(), ukey, nil)
+	require.Nil(err, "%+v", err)
+	assert.Equal(hex.EncodeToString(ukey), acct.ID)
+	// and the only one we accept, the raw address is not an id
+	_, err = view.AccountByKey(app.Snapshot(), earl.PubKey().Address(), nil)
+	assert.NotNil(err)
+	_, err = view.PostsForAccount(app.Snapshot(), earl.PubKey().Address(), store.Page{Limit: 10}, false)
+	assert.NotNil(err)
+	assert.NotNil(app.TrustAttesters([][]byte{earl.PubKey().Address()}))
+	assert.Nil(app.TrustAttesters([][]byte{ukey}))
+}
+
+func TestEndorsements(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice, bob := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	atx, err := sign.Send(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.Nil(err, "%+v", err)
+	btx, err := sign.Send(txn.CreateAccountAction{Name: "Bob"}, bob)
+	require.Nil(err, "%+v", err)
+	app.AppendTx(atx)
+	app.AppendTx(btx)
+	ptx, err := sign.Send(txn.AddPostAction{Title: "Contract", Sequence: 1}, alice)
+	require.Nil(err, "%+v", err)
+	pres := app.AppendTx(ptx)
+	require.False(pres.IsErr(), pres.Error())
+	etx, err := sign.Send(txn.EndorsePostAction{Post: pres.Data, Comment: "Signed", Sequence: 1}, bob)
+	require.Nil(err, "%+v", err)
+	eres := app.AppendTx(etx)
+	require.False(eres.IsErr(), eres.Error())
+	app.EndBlock(1)
+	hash := app.Commit().Data
+
+	// the post lists the endorser
+	post, err := view.PostByKey(app.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	bobAcct, err := view.AccountBySigner(app.Snapshot(), bob.PubKey().Address(), nil)
+	require.Nil(err, "%+v", err)
+	assert.Equal([]string{bobAcct.ID}, post.Endorsers)
+
+	// and each endorsement can be proven
+	ends, err := view.PostEndorsements(app.Snapshot(), pres.Data)
+	require.Nil(err, "%+v", err)
+	require.EqualValues(1, ends.Count)
+	end := ends.Items[0]
+	assert.Equal(bobAcct.ID, end.AccountID)
+	assert.Equal("Signed", end.Comment)
+	assert.EqualValues(1, end.PublishedBlock)
+	require.NotNil(end.Proof)
+	assert.Equal(hex.EncodeToString(eres.Data), end.Proof.Key)
+	value, err := hex.DecodeString(end.Proof.Value)
+	require.Nil(err)
+	pbytes, err := hex.DecodeString(end.Proof.Proof)
+	require.Nil(err)
+	iavl := merkle.IAVLProof{}
+	require.Nil(wutil.FromBinary(pbytes, &iavl))
+	assert.True(iavl.Verify(eres.Data, value, hash))
+}
+
+func TestAttestations(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice, notary, stranger := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	names := map[string]crypto.PrivKey{"Alice": alice, "Notary": notary, "Stranger": stranger}
+	for name, key := range names {
+		tx, err := sign.Send(txn.CreateAccountAction{Name: name}, key)
+		require.Nil(err, "%+v", err)
+		res := app.AppendTx(tx)
+		require.False(res.IsErr(), res.Error())
+	}
+	subject := alice.PubKey().Address()
+	ntx, err := sign.Send(txn.AttestIdentityAction{Subject: subject, Claim: "email", Value: "alice@example.com", Sequence: 1}, notary)
+	require.Nil(err, "%+v", err)
+	stx, err := sign.Send(txn.AttestIdentityAction{Subject: subject, Claim: "legal-name", Value: "Queen Alice", Sequence: 1}, stranger)
+	require.Nil(err, "%+v", err)
+	res := app.AppendTx(ntx)
+	require.False(res.IsErr(), res.Error())
+	res = app.AppendTx(stx)
+	require.False(res.IsErr(), res.Error())
+	rtx, err := sign.Send(txn.RevokeAttestationAction{Subject: subject, Claim: "legal-name", Sequence: 2}, stranger)
+	require.Nil(err, "%+v", err)
+	res = app.AppendTx(rtx)
+	require.False(res.IsErr(), res.Error())
+	app.EndBlock(1)
+	app.Commit()
+
+	// the account only shows the valid claim, flagged if we trust the attester
+	require.Nil(app.TrustAttesters([][]byte{accountID(notary.PubKey().Address())}))
+	acct, err := view.AccountByKey(app.Snapshot(), accountID(subject), app.trusted)
+	require.Nil(err, "%+v", err)
+	if assert.Equal(1, len(acct.Attestations)) {
+		att := acct.Attestations[0]
+		assert.Equal("email", att.Claim)
+		assert.Equal("alice@example.com", att.Value)
+		assert.True(att.Valid)
+		assert.True(att.Trusted)
+	}
+	acct, err = view.AccountByKey(app.Snapshot(), accountID(subject), nil)
+	require.Nil(err, "%+v", err)
+	if assert.Equal(1, len(acct.Attestations)) {
+		assert.False(acct.Attestations[0].Trusted)
+	}
+
+	// while the full list also has the revoked one
+	atts, err := view.AccountAttestations(app.Snapshot(), accountID(subject), app.trusted)
+	require.Nil(err, "%+v", err)
+	require.EqualValues(2, atts.Count)
+	for _, att := range atts.Items {
+		if att.Claim == "legal-name" {
+			assert.True(att.Revoked)
+			assert.False(att.Valid)
+			assert.False(att.Trusted)
+		}
+	}
+}
+
+func TestThreads(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice, bob := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	send := func(action sign.Action, key crypto.PrivKey) []byte {
+		tx, err := sign.Send(action, key)
+		require.Nil(err, "%+v", err)
+		res := app.AppendTx(tx)
+		require.False(res.IsErr(), res.Error())
+		return res.Data
+	}
+	send(txn.CreateAccountAction{Name: "Alice"}, alice)
+	send(txn.CreateAccountAction{Name: "Bob"}, bob)
+	root := send(txn.AddPostAction{Title: "Root", Sequence: 1}, alice)
+	first := send(txn.AddPostAction{Title: "First", Parent: root, Sequence: 1}, bob)
+	send(txn.AddPostAction{Title: "Second", Parent: root, Sequence: 2}, bob)
+	deep := send(txn.AddPostAction{Title: "Deep", Parent: first, Sequence: 2}, alice)
+	send(txn.AddPostAction{Title: "Deeper", Parent: deep, Sequence: 3}, bob)
+	app.EndBlock(1)
+	app.Commit()
+
+	// replies are listed oldest first, and can be paged
+	replies, err := view.PostReplies(app.Snapshot(), root, store.Page{Limit: 1}, false)
+	require.Nil(err, "%+v", err)
+	require.EqualValues(1, replies.Count)
+	assert.Equal("First", replies.Items[0].Title)
+	assert.Equal(hex.EncodeToString(root), replies.Items[0].Parent)
+	require.NotEmpty(replies.Next)
+	after, err := hex.DecodeString(replies.Next)
+	require.Nil(err)
+	replies, err = view.PostReplies(app.Snapshot(), root, store.Page{Limit: 1, After: after}, false)
+	require.Nil(err, "%+v", err)
+	require.EqualValues(1, replies.Count)
+	assert.Equal("Second", replies.Items[0].Title)
+	assert.Empty(replies.Next)
+
+	// the thread stops at the given depth
+	thread, err := view.PostThread(app.Snapshot(), root, 2, 0, 100, false)
+	require.Nil(err, "%+v", err)
+	assert.Equal("Root", thread.Title)
+	assert.EqualValues(2, thread.Replies)
+	require.Equal(2, len(thread.Children))
+	assert.Equal("First", thread.Children[0].Title)
+	require.Equal(1, len(thread.Children[0].Children))
+	deepest := thread.Children[0].Children[0]
+	assert.Equal("Deep", deepest.Title)
+	assert.EqualValues(1, deepest.Replies)
+	assert.Empty(deepest.Children)
+
+	// and shows only the first replies of each post
+	thread, err = view.PostThread(app.Snapshot(), root, 1, 1, 100, false)
+	require.Nil(err, "%+v", err)
+	require.Equal(1, len(thread.Children))
+	assert.Equal("First", thread.Children[0].Title)
+	assert.False(thread.Truncated)
+
+	// the node budget fills the levels in order, and marks where it cut
+	thread, err = view.PostThread(app.Snapshot(), root, 3, 0, 4, false)
+	require.Nil(err, "%+v", err)
+	require.Equal(2, len(thread.Children))
+	assert.False(thread.Truncated)
+	require.Equal(1, len(thread.Children[0].Children))
+	deepest = thread.Children[0].Children[0]
+	assert.Empty(deepest.Children)
+	assert.True(deepest.Truncated)
+
+	// retracted replies can be left out, along with the replies below them
+	send(txn.RetractPostAction{Number: 1, Sequence: 4}, bob)
+	app.EndBlock(2)
+	app.Commit()
+	replies, err = view.PostReplies(app.Snapshot(), root, store.Page{}, true)
+	require.Nil(err, "%+v", err)
+	require.EqualValues(1, replies.Count)
+	assert.Equal("Second", replies.Items[0].Title)
+	thread, err = view.PostThread(app.Snapshot(), root, 3, 0, 100, true)
+	require.Nil(err, "%+v", err)
+	require.Equal(1, len(thread.Children))
+	assert.Equal("Second", thread.Children[0].Title)
+	thread, err = view.PostThread(app.Snapshot(), root, 3, 0, 100, false)
+	require.Nil(err, "%+v", err)
+	require.Equal(2, len(thread.Children))
+	assert.True(thread.Children[0].Retracted)
+}
+
+func TestStream(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice, bob := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	block := func(height uint64, txs ...[]byte) {
+		app.BeginBlock(height)
+		for _, tx := range txs {
+			app.AppendTx(tx)
+		}
+		app.EndBlock(height)
+		app.Commit()
+	}
+	tx := func(action sign.Action, key crypto.PrivKey) []byte {
+		data, err := sign.Send(action, key)
+		require.Nil(err, "%+v", err)
+		return data
+	}
+
+	all, _, err := app.stream.subscribe(streamFilter{}, streamPos{})
+	require.Nil(err)
+	posts, _, err := app.stream.subscribe(streamFilter{actions: map[string]bool{"post": true}}, streamPos{})
+	require.Nil(err)
+
+	// failed txs send no event
+	block(1, tx(txn.CreateAccountAction{Name: "Alice"}, alice), tx(txn.CreateAccountAction{Name: "Bob"}, bob),
+		tx(txn.AddPostAction{Title: "Bad sequence", Sequence: 7}, alice))
+	block(2, tx(txn.AddPostAction{Title: "Hello", Sequence: 1}, alice))
+
+	aliceAcct, err := view.AccountBySigner(app.Snapshot(), alice.PubKey().Address(), nil)
+	require.Nil(err, "%+v", err)
+	expected := []struct {
+		height uint64
+		action string
+		post   string
+	}{
+		{1, "account", ""},
+		{1, "account", ""},
+		{2, "post", "Hello"},
+	}
+	for _, e := range expected {
+		ev := <-all.events
+		assert.Equal(e.height, ev.Height)
+		assert.Equal(e.action, ev.Type)
+		require.NotNil(ev.Account)
+		if e.post != "" && assert.NotNil(ev.Post) {
+			assert.Equal(e.post, ev.Post.Title)
+			assert.Equal(aliceAcct.ID, ev.Account.ID)
+		}
+	}
+	ev := <-posts.events
+	assert.Equal("post", ev.Type)
+	assert.Empty(posts.events)
+
+	// a new client can resume from a past height, filtered by account
+	bobAcct, err := view.AccountBySigner(app.Snapshot(), bob.PubKey().Address(), nil)
+	require.Nil(err, "%+v", err)
+	r := mux.NewRouter()
+	app.AddQueryRoutes(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/stream?from=1&account=" + bobAcct.ID)
+	require.Nil(err)
+	defer resp.Body.Close()
+	require.Equal(200, resp.StatusCode)
+	assert.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	lines := bufio.NewReader(resp.Body)
+	for _, want := range []string{"id: 1-1", "event: account", "data: "} {
+		line, err := lines.ReadString('\n')
+		require.Nil(err)
+		assert.True(strings.HasPrefix(line, want), line)
+	}
+
+	// and a reconnecting client resumes right after the last event it got, even within a block
+	req, err := http.NewRequest("GET", srv.URL+"/stream?from=1", nil)
+	require.Nil(err)
+	req.Header.Set("Last-Event-ID", "1-0")
+	resp2, err := http.DefaultClient.Do(req)
+	require.Nil(err)
+	defer resp2.Body.Close()
+	require.Equal(200, resp2.StatusCode)
+	lines = bufio.NewReader(resp2.Body)
+	ids := []string{}
+	for len(ids) < 2 {
+		line, err := lines.ReadString('\n')
+		require.Nil(err)
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimSpace(line))
+		}
+	}
+	assert.Equal([]string{"id: 1-1", "id: 2-0"}, ids)
+
+	// after a restart, we cannot resume from before the first block we published
+	restarted := newStream(3)
+	_, _, err = restarted.subscribe(streamFilter{}, streamPos{height: 2, index: -1})
+	assert.Equal(utils.CodeBadRequest, utils.ToError(err).Code)
+	_, _, err = restarted.subscribe(streamFilter{}, streamPos{height: 2, index: 5})
+	assert.NotNil(err)
+	_, missed, err := restarted.subscribe(streamFilter{}, streamPos{height: 3, index: -1})
+	assert.Nil(err)
+	assert.Empty(missed)
+}
+
+func TestAtom(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice, bob := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	var postKeys [][]byte
+	for i, action := range []struct {
+		action sign.Action
+		key    crypto.PrivKey
+	}{
+		{txn.CreateAccountAction{Name: "Alice"}, alice},
+		{txn.CreateAccountAction{Name: "Bob"}, bob},
+		{txn.AddPostAction{Title: "First", Sequence: 1}, alice},
+		{txn.AddPostAction{Title: "Second", Sequence: 1}, bob},
+	} {
+		app.BeginBlock(uint64(i + 1))
+		tx, err := sign.Send(action.action, action.key)
+		require.Nil(err, "%+v", err)
+		res := app.AppendTx(tx)
+		require.False(res.IsErr(), res.Error())
+		if i >= 2 {
+			postKeys = append(postKeys, res.Data)
+		}
+		app.EndBlock(uint64(i + 1))
+		app.Commit()
+	}
+
+	r := mux.NewRouter()
+	app.AddQueryRoutes(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	get := func(path, etag string) (*http.Response, *view.AtomFeed) {
+		req, err := http.NewRequest("GET", srv.URL+path, nil)
+		require.Nil(err)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(err)
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return resp, nil
+		}
+		assert.Equal("application/atom+xml", resp.Header.Get("Content-Type"))
+		feed := new(view.AtomFeed)
+		require.Nil(xml.NewDecoder(resp.Body).Decode(feed))
+		return resp, feed
+	}
+
+	// the global feed has all posts, newest first, with stable ids and a link to the proof
+	resp, feed := get("/feed.atom", "")
+	require.NotNil(feed)
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(etag)
+	// updated when we committed the state
+	updated, err := time.Parse(time.RFC3339, feed.Updated)
+	require.Nil(err)
+	assert.True(time.Since(updated) < time.Minute)
+	if assert.Equal(2, len(feed.Entries)) {
+		entry := feed.Entries[0]
+		assert.Equal("Second", entry.Title)
+		assert.Equal("Bob", entry.Author.Name)
+		assert.Equal("urn:signedpost:post:"+hex.EncodeToString(postKeys[1]), entry.ID)
+		assert.Contains(entry.Links, view.AtomLink{Rel: "related", Type: "application/json",
+			Href: srv.URL + "/posts/" + hex.EncodeToString(postKeys[1]) + "/proof"})
+		// dated when we committed the block, as we cannot ask tendermint here
+		published, err := time.Parse(time.RFC3339, entry.Published)
+		require.Nil(err)
+		assert.True(time.Since(published) < time.Minute)
+		assert.Equal(entry.Published, entry.Updated)
+	}
+
+	// which only changes with the app hash
+	resp, _ = get("/feed.atom", etag)
+	assert.Equal(http.StatusNotModified, resp.StatusCode)
+	app.BeginBlock(5)
+	tx, err := sign.Send(txn.AddPostAction{Title: "Third", Sequence: 2}, alice)
+	require.Nil(err, "%+v", err)
+	app.AppendTx(tx)
+	app.EndBlock(5)
+	app.Commit()
+	resp, feed = get("/feed.atom", etag)
+	assert.Equal(200, resp.StatusCode)
+	assert.NotEqual(etag, resp.Header.Get("ETag"))
+	require.NotNil(feed)
+	assert.Equal(3, len(feed.Entries))
+
+	// the account feed only has the posts of the account
+	acct, err := view.AccountBySigner(app.Snapshot(), alice.PubKey().Address(), nil)
+	require.Nil(err, "%+v", err)
+	resp, feed = get("/accounts/"+acct.ID+"/feed.atom", "")
+	etag = resp.Header.Get("ETag")
+	require.NotNil(feed)
+	assert.Equal("urn:signedpost:account:"+acct.ID, feed.ID)
+	if assert.Equal(2, len(feed.Entries)) {
+		assert.Equal("Third", feed.Entries[0].Title)
+		assert.Equal("First", feed.Entries[1].Title)
+	}
+
+	// both feeds can leave out retracted posts
+	app.BeginBlock(6)
+	tx, err = sign.Send(txn.RetractPostAction{Number: 1, Sequence: 3}, alice)
+	require.Nil(err, "%+v", err)
+	require.False(app.AppendTx(tx).IsErr())
+	app.EndBlock(6)
+	app.Commit()
+	_, feed = get("/feed.atom?retracted=hide", "")
+	require.NotNil(feed)
+	assert.Equal(2, len(feed.Entries))
+	resp, feed = get("/accounts/"+acct.ID+"/feed.atom?retracted=hide", "")
+	require.NotNil(feed)
+	if assert.Equal(1, len(feed.Entries)) {
+		assert.Equal("Third", feed.Entries[0].Title)
+	}
+	etag = resp.Header.Get("ETag")
+
+	// but bad or unknown ids are errors, whatever the client has cached
+	resp, _ = get("/accounts/1234/feed.atom", etag)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	resp, _ = get("/accounts/"+hex.EncodeToString(accountID([]byte("nobody")))+"/feed.atom", etag)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func TestErrors(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+	app.BeginBlock(1)
+	tx, err := sign.Send(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.Nil(err, "%+v", err)
+	require.False(app.AppendTx(tx).IsErr())
+	app.EndBlock(1)
+	app.Commit()
+
+	r := mux.NewRouter()
+	app.AddQueryRoutes(r)
+	// nothing listens here, so every broadcast fails
+	NewProxy("http://127.0.0.1:1", app).AddChainRoutes(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	check := func(resp *http.Response, err error, status int, code string) {
+		require.Nil(err)
+		defer resp.Body.Close()
+		assert.Equal(status, resp.StatusCode)
+		assert.Equal("application/json", resp.Header.Get("Content-Type"))
+		res := utils.ErrorResponse{}
+		require.Nil(json.NewDecoder(resp.Body).Decode(&res))
+		assert.Equal(code, res.Error.Code)
+		assert.NotEmpty(res.Error.Message)
+	}
+
+	resp, err := http.Get(srv.URL + "/accounts/zz")
+	check(resp, err, http.StatusBadRequest, utils.CodeBadRequest)
+	// a raw address is not an account id
+	resp, err = http.Get(srv.URL + "/accounts/" + hex.EncodeToString([]byte("nobody")))
+	check(resp, err, http.StatusBadRequest, utils.CodeBadRequest)
+	nobody, err := mom.KeyToBytes(store.AccountKey{ID: []byte("nobody")})
+	require.Nil(err)
+	resp, err = http.Get(srv.URL + "/accounts/" + hex.EncodeToString(nobody))
+	check(resp, err, http.StatusNotFound, utils.CodeNotFound)
+	resp, err = http.Get(srv.URL + "/accounts?limit=0")
+	check(resp, err, http.StatusBadRequest, utils.CodeBadRequest)
+	resp, err = http.Get(srv.URL + "/accounts?height=99")
+	check(resp, err, http.StatusBadRequest, utils.CodeBadRequest)
+	// nor is it a post id
+	resp, err = http.Get(srv.URL + "/posts/" + hex.EncodeToString(nobody))
+	check(resp, err, http.StatusBadRequest, utils.CodeBadRequest)
+	resp, err = http.Get(srv.URL + "/posts/" + hex.EncodeToString(nobody) + "/proof")
+	check(resp, err, http.StatusBadRequest, utils.CodeBadRequest)
+	// anything we did not expect is our fault, not the client's
+	assert.Equal(utils.CodeInternal, utils.ToError(errors.New("disk full")).Code)
+	assert.Equal(utils.CodeBadRequest, utils.ToError(utils.BadRequest(errors.New("bad"))).Code)
+
+	// a broken signature is rejected before we talk to the chain
+	tx, err = sign.Send(txn.AddPostAction{Title: "Hi", Sequence: 1}, alice)
+	require.Nil(err, "%+v", err)
+	bad := append([]byte{}, tx...)
+	bad[len(bad)-1] ^= 0xff
+	resp, err = http.Post(srv.URL+"/tndr/tx", "application/json", strings.NewReader(`{"tx": "`+hex.EncodeToString(bad)+`"}`))
+	check(resp, err, http.StatusUnprocessableEntity, utils.CodeRejected)
+	resp, err = http.Post(srv.URL+"/tndr/tx", "application/json", strings.NewReader(`{"tx": "`+hex.EncodeToString(tx)+`"}`))
+	check(resp, err, http.StatusBadGateway, utils.CodeChain)
+
+	// and the result of CheckTx is passed on
+	e := utils.ToError(txError(nil, tmsp.CodeType_BaseInvalidSequence, "Bad sequence"))
+	assert.Equal(utils.CodeRejected, e.Code)
+	assert.Equal(txResult{Code: tmsp.CodeType_BaseInvalidSequence, CodeName: "BaseInvalidSequence", Log: "Bad sequence"}, e.Details)
+	assert.Equal(utils.CodeNotFound, utils.ToError(txError(nil, tmsp.CodeType_BaseUnknownAddress, "")).Code)
+	assert.Equal(utils.CodeBadRequest, utils.ToError(txError(nil, tmsp.CodeType_BaseInvalidInput, "")).Code)
+
+	// the app codes are the same from CheckTx and AppendTx, and documented
+	tx, err = sign.Send(txn.CreateAccountAction{Name: "Alice"}, crypto.GenPrivKeyEd25519())
+	require.Nil(err, "%+v", err)
+	assert.Equal(redux.CodeNameTaken, app.CheckTx(tx).Code)
+	assert.Equal(redux.CodeNameTaken, app.AppendTx(tx).Code)
+	e = utils.ToError(txError(nil, redux.CodeNameTaken, "Account name already taken"))
+	assert.Equal(utils.CodeRejected, e.Code)
+	assert.Equal("Transaction rejected: name_taken: Account name already taken", e.Message)
+	assert.Equal("name_taken", e.Details.(txResult).CodeName)
+	assert.Equal(utils.CodeNotFound, utils.ToError(txError(nil, redux.CodeNoPost, "")).Code)
+	assert.Equal(utils.CodeInternal, utils.ToError(txError(nil, redux.CodeStorage, "")).Code)
+
+	resp, err = http.Get(srv.URL + "/codes")
+	require.Nil(err)
+	defer resp.Body.Close()
+	var codes []redux.ErrorCode
+	require.Nil(json.NewDecoder(resp.Body).Decode(&codes))
+	assert.Equal(redux.ErrorCodes(), codes)
+}
+
+// fakeChain runs the txs against the app in place of tendermint core, one block per tx in commit mode
+type fakeChain struct {
+	client.Client
+	app *Application
+}
+
+func (c fakeChain) BroadcastTxAsync(tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+	return &ctypes.ResultBroadcastTx{}, nil
+}
+
+func (c fakeChain) BroadcastTxSync(tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+	res := c.app.CheckTx(tx)
+	return &ctypes.ResultBroadcastTx{Code: res.Code, Data: res.Data, Log: res.Log}, nil
+}
+
+func (c fakeChain) BroadcastTxCommit(tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+	if res := c.app.CheckTx(tx); res.IsErr() {
+		return nil, errors.New("Check tx failed")
+	}
+	height := c.app.last.Height + 1
+	c.app.BeginBlock(height)
+	res := c.app.AppendTx(tx)
+	c.app.EndBlock(height)
+	c.app.Commit()
+	return &ctypes.ResultBroadcastTx{Code: res.Code, Data: res.Data, Log: res.Log}, nil
+}
+
+func TestTxStatus(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice, bob := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+	app := NewApp(merkle.NewIAVLTree(0, nil))
+
+	r := mux.NewRouter()
+	app.AddQueryRoutes(r)
+	Proxy{client: fakeChain{app: app}, app: app}.AddChainRoutes(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	send := func(action sign.Action, key crypto.PrivKey, mode string) (*http.Response, []byte) {
+		tx, err := sign.Send(action, key)
+		require.Nil(err, "%+v", err)
+		resp, err := http.Post(srv.URL+"/tndr/tx?mode="+mode, "application/json",
+			strings.NewReader(`{"tx": "`+hex.EncodeToString(tx)+`"}`))
+		require.Nil(err)
+		return resp, tx
+	}
+	status := func(tx []byte) *TxStatus {
+		resp, err := http.Get(srv.URL + "/tndr/tx/" + hex.EncodeToString(TxHash(tx)))
+		require.Nil(err)
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return nil
+		}
+		st := new(TxStatus)
+		require.Nil(json.NewDecoder(resp.Body).Decode(st))
+		return st
+	}
+
+	// commit mode waits for the block, and tells us where the tx ended up
+	resp, tx := send(txn.CreateAccountAction{Name: "Alice"}, alice, "commit")
+	require.Equal(200, resp.StatusCode)
+	var res txResponse
+	require.Nil(json.NewDecoder(resp.Body).Decode(&res))
+	resp.Body.Close()
+	assert.Equal(hex.EncodeToString(TxHash(tx)), res.Hash)
+	assert.EqualValues(1, res.Height)
+	st := status(tx)
+	require.NotNil(st)
+	assert.Equal(TxCommitted, st.Status)
+	assert.EqualValues(1, st.Height)
+	assert.Equal(res.Data, st.Data)
+
+	// if CheckTx fails, commit mode reports why
+	resp, tx = send(txn.CreateAccountAction{Name: "Alice"}, bob, "commit")
+	resp.Body.Close()
+	assert.Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+	st = status(tx)
+	require.NotNil(st)
+	assert.Equal(TxRejected, st.Status)
+	assert.Equal(redux.CodeNameTaken, st.Code)
+	assert.Equal("name_taken", st.CodeName)
+	assert.EqualValues(0, st.Height)
+
+	// sync mode only waits for CheckTx, so the tx is pending until its block is committed
+	resp, tx = send(txn.CreateAccountAction{Name: "Bob"}, bob, "")
+	resp.Body.Close()
+	assert.Equal(200, resp.StatusCode)
+	assert.Equal(TxPending, status(tx).Status)
+	app.BeginBlock(2)
+	app.AppendTx(tx)
+	// a tx that fails in the block (proposed by another node) is rejected at that height
+	late, err := sign.Send(txn.AddPostAction{Title: "Late", Sequence: 5}, alice)
+	require.Nil(err, "%+v", err)
+	app.AppendTx(late)
+	app.EndBlock(2)
+	assert.Equal(TxPending, status(tx).Status)
+	app.Commit()
+	st = status(tx)
+	assert.Equal(TxCommitted, st.Status)
+	assert.EqualValues(2, st.Height)
+	st = status(late)
+	assert.Equal(TxRejected, st.Status)
+	assert.EqualValues(2, st.Height)
+	assert.Equal(redux.CodeBadSequence, st.Code)
+
+	// async mode does not even wait for CheckTx
+	resp, tx = send(txn.AddPostAction{Title: "Hi", Sequence: 1}, alice, "async")
+	resp.Body.Close()
+	assert.Equal(200, resp.StatusCode)
+	assert.Equal(TxUnknown, status(tx).Status)
+	resp, _ = send(txn.AddPostAction{Title: "Hi", Sequence: 1}, alice, "later")
+	resp.Body.Close()
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	// txs we do not track are unknown, not rejected
+	st = status([]byte("nothing"))
+	require.NotNil(st)
+	assert.Equal(TxUnknown, st.Status)
+	assert.NotEmpty(st.Log)
+	assert.Empty(st.CodeName)
+}
+
+// accountID is the id we render for the account created by this address
+func accountID(addr []byte) []byte {
+	id, err := mom.KeyToBytes(store.AccountKey{ID: addr})
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func TestFeedCache(t *testing.T) {
+	assert := assert.New(t)
+	cache := newFeedCache()
+	snap := view.Snapshot{Height: 3, Hash: []byte{1, 2, 3}}
+	feed := &view.AtomFeed{Height: 3}
+
+	// a feed is only reused for the same state and request
+	assert.Nil(cache.get(snap, "http://a", false))
+	cache.put(snap, "http://a", false, feed)
+	assert.Equal(feed, cache.get(snap, "http://a", false))
+	assert.Nil(cache.get(snap, "http://a", true))
+	assert.Nil(cache.get(snap, "http://b", false))
+
+	// and forgotten with the next commit
+	next := view.Snapshot{Height: 4, Hash: []byte{4, 5, 6}}
+	assert.Nil(cache.get(next, "http://a", false))
+	cache.put(next, "http://a", true, &view.AtomFeed{Height: 4})
+	assert.Nil(cache.get(snap, "http://a", false))
 }
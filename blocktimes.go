@@ -0,0 +1,115 @@
+package signedpost
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	dbm "github.com/tendermint/go-db"
+)
+
+// maxBlockTimes is the number of block times we keep in memory, the rest is reloaded from the db
+const maxBlockTimes = 10000
+
+// blockTimes finds the time of a block, to show when posts were published.
+// This is not part of the consensus state: TMSP only passes us the height of a block,
+// so we take the time from the block header (on replay, or from tendermint), or else
+// the time we committed the block, which is close to it as long as we keep up with the chain.
+// The times are stored in the db next to the commit state, so they survive a restart
+type blockTimes struct {
+	mtx      sync.Mutex
+	headers  map[uint64]time.Time // from the block header
+	observed map[uint64]time.Time // when we committed it
+	db       dbm.DB               // nil if in-memory
+	// chain looks up the header time from tendermint, nil if we have no connection
+	chain func(height uint64) (time.Time, error)
+}
+
+func newBlockTimes(db dbm.DB) *blockTimes {
+	return &blockTimes{
+		headers:  map[uint64]time.Time{},
+		observed: map[uint64]time.Time{},
+		db:       db,
+	}
+}
+
+func headerTimeKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("signedpost/time/header/%d", height))
+}
+
+func observedTimeKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("signedpost/time/observed/%d", height))
+}
+
+// header records the time in the header of this block
+func (b *blockTimes) header(height uint64, t time.Time) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.set(b.headers, headerTimeKey(height), height, t)
+}
+
+// observe records when we committed this block
+func (b *blockTimes) observe(height uint64, t time.Time) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.set(b.observed, observedTimeKey(height), height, t)
+}
+
+func (b *blockTimes) set(cache map[uint64]time.Time, key []byte, height uint64, t time.Time) {
+	if len(cache) >= maxBlockTimes {
+		for h := range cache {
+			delete(cache, h)
+		}
+	}
+	cache[height] = t
+	if b.db != nil {
+		b.db.Set(key, []byte(t.UTC().Format(time.RFC3339Nano)))
+	}
+}
+
+func (b *blockTimes) get(cache map[uint64]time.Time, key []byte, height uint64) (time.Time, bool) {
+	if t, ok := cache[height]; ok {
+		return t, true
+	}
+	if b.db == nil {
+		return time.Time{}, false
+	}
+	data := b.db.Get(key)
+	if len(data) == 0 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		return time.Time{}, false
+	}
+	cache[height] = t
+	return t, true
+}
+
+// BlockTime returns the time of the block at this height, zero if unknown.
+// The header time is preferred, then the time we committed the block
+func (b *blockTimes) BlockTime(height uint64) time.Time {
+	if height == 0 {
+		return time.Time{}
+	}
+	b.mtx.Lock()
+	t, ok := b.get(b.headers, headerTimeKey(height), height)
+	chain := b.chain
+	b.mtx.Unlock()
+	if ok {
+		return t
+	}
+
+	// ask tendermint without holding the lock, this may take a while
+	if chain != nil {
+		if t, err := chain(height); err == nil && !t.IsZero() {
+			b.header(height, t)
+			return t
+		}
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	t, _ = b.get(b.observed, observedTimeKey(height), height)
+	return t
+}
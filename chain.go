@@ -5,72 +5,197 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 
+	"github.com/ethanfrey/signedpost/redux"
 	"github.com/ethanfrey/signedpost/utils"
 	"github.com/ethanfrey/tenderize/client"
 	"github.com/ethanfrey/tenderize/sign"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	tmsp "github.com/tendermint/tmsp/types"
 )
 
 const minTxLength = 20
 const maxBlocks = 50
 
+// Broadcast modes for POST /tndr/tx?mode=
+const (
+	modeAsync  = "async"  // return right away, before CheckTx
+	modeSync   = "sync"   // return once CheckTx accepted the tx (default)
+	modeCommit = "commit" // return once the tx is in a block
+)
+
 // Proxy validates queries and sends appropriate ones to the tendermint core
 type Proxy struct {
 	client client.Client
+	app    *Application // we look up the status of txs in its index
 }
 
-// NewProxy creates a Proxy pointing to the url of the rpc server on tendermint core
-func NewProxy(baseURL string) Proxy {
+// NewProxy creates a Proxy pointing to the url of the rpc server on tendermint core,
+// for the app that runs in the same process
+func NewProxy(baseURL string, app *Application) Proxy {
 	return Proxy{
 		client: client.New(baseURL, "/websocket"),
+		app:    app,
+	}
+}
+
+// blockTime looks up the time in the header of the block at this height
+func (p Proxy) blockTime(height uint64) (time.Time, error) {
+	res, err := p.client.Block(int(height))
+	if err != nil {
+		return time.Time{}, err
 	}
+	if res.BlockMeta == nil || res.BlockMeta.Header == nil {
+		return time.Time{}, errors.Errorf("No header for block %d", height)
+	}
+	return res.BlockMeta.Header.Time, nil
 }
 
 type txPost struct {
 	TX string `json:"tx"`
 }
 
+// txResponse is the result of a broadcast, with the hash to look up the status of the tx later
+type txResponse struct {
+	*ctypes.ResultBroadcastTx
+	Hash   string `json:"hash"`
+	Height uint64 `json:"height,omitempty"` // block with the tx, only in commit mode
+}
+
 // PostTransaction validates the posted transaction and submits it to the tendermint consensus engine
-// Format: {"tx": "deadbeef"} - hex encoded transaction as tx key in a json blob
+// Format: {"tx": "deadbeef"} - hex encoded transaction as tx key in a json blob.
+// ?mode=async|sync|commit sets how long we wait for the result
 func (p Proxy) PostTransaction(rw http.ResponseWriter, r *http.Request) {
-	var res *ctypes.ResultBroadcastTx
+	var res *txResponse
 	var tx []byte
+	var err error
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != modeAsync && mode != modeSync && mode != modeCommit {
+		err = utils.BadRequest(errors.Errorf("Invalid mode %q, must be async, sync or commit", mode))
+	}
 	post := txPost{}
-	dec := json.NewDecoder(r.Body)
-	err := dec.Decode(&post)
+	if err == nil {
+		err = json.NewDecoder(r.Body).Decode(&post)
+	}
 	if err == nil {
 		tx, err = hex.DecodeString(post.TX)
 		if len(tx) < minTxLength {
-			err = errors.New("tx data truncated or missing")
+			err = utils.BadRequest(errors.New("tx data truncated or missing"))
 		}
 	}
 	if err == nil {
 		var val sign.ValidatedAction
 		val, err = sign.Receive(tx)
-		if err == nil && val.IsAnon() {
-			err = errors.New("All transactions require a valid signature")
+		if err != nil {
+			err = utils.ErrRejected(err.Error(), nil)
+		} else if val.IsAnon() {
+			err = utils.ErrRejected("All transactions require a valid signature", nil)
 		}
 	}
 	// at this point, we have an error, or we known body is an acceptable transaction
 	if err == nil {
-		res, err = p.client.BroadcastTxSync(tx)
+		res, err = p.broadcast(mode, tx)
 	}
 	utils.RenderQuery(rw, res, err)
 }
 
+// broadcast sends the tx to tendermint core, and maps a rejection to an error
+func (p Proxy) broadcast(mode string, tx []byte) (*txResponse, error) {
+	var res *ctypes.ResultBroadcastTx
+	var err error
+	switch mode {
+	case modeAsync:
+		res, err = p.client.BroadcastTxAsync(tx)
+	case modeCommit:
+		res, err = p.client.BroadcastTxCommit(tx)
+	default:
+		res, err = p.client.BroadcastTxSync(tx)
+	}
+
+	hash := TxHash(tx)
+	st := p.app.txs.get(hash)
+	if err != nil {
+		// in commit mode tendermint only returns an error if CheckTx failed, but we saw why
+		if st != nil && st.Status == TxRejected {
+			return nil, txError(hash, st.Code, st.Log)
+		}
+		return nil, utils.ErrChain(err)
+	}
+	if res.Code != tmsp.CodeType_OK {
+		return nil, txError(hash, res.Code, res.Log)
+	}
+
+	out := &txResponse{ResultBroadcastTx: res, Hash: hex.EncodeToString(hash)}
+	if mode == modeCommit && st != nil {
+		out.Height = st.Height
+	}
+	return out, nil
+}
+
+// txResult is the details of a failed CheckTx, so the client knows why the app refused the tx
+type txResult struct {
+	Hash        string        `json:"hash,omitempty"`
+	Code        tmsp.CodeType `json:"tmsp_code"`
+	CodeName    string        `json:"tmsp_code_name"`
+	Description string        `json:"description,omitempty"`
+	Log         string        `json:"log"`
+}
+
+// txError maps the code returned by CheckTx to the error we report to the client
+func txError(hash []byte, code tmsp.CodeType, log string) error {
+	res := txResult{Hash: hex.EncodeToString(hash), Code: code, CodeName: code.String(), Log: log}
+	ec, ok := redux.LookupErrorCode(code)
+	if ok {
+		res.CodeName, res.Description = ec.Name, ec.Description
+	}
+	msg := "Transaction rejected: " + res.CodeName
+	if log != "" {
+		msg += ": " + log
+	}
+	if ok {
+		switch ec.Kind {
+		case redux.KindInvalid:
+			return utils.NewError(utils.CodeBadRequest, msg, res)
+		case redux.KindNotFound:
+			return utils.NewError(utils.CodeNotFound, msg, res)
+		case redux.KindInternal:
+			return utils.NewError(utils.CodeInternal, msg, res)
+		}
+		return utils.ErrRejected(msg, res)
+	}
+
+	// the generic tmsp codes
+	switch code {
+	case tmsp.CodeType_BaseUnknownAddress:
+		return utils.NewError(utils.CodeNotFound, msg, res)
+	case tmsp.CodeType_EncodingError, tmsp.CodeType_BaseEncodingError, tmsp.CodeType_BaseInvalidInput:
+		return utils.NewError(utils.CodeBadRequest, msg, res)
+	case tmsp.CodeType_InternalError:
+		return utils.NewError(utils.CodeInternal, msg, res)
+	}
+	// bad signatures, sequences, duplicates...
+	return utils.ErrRejected(msg, res)
+}
+
 // GetStatus returns the status of the tendermint core
 func (p Proxy) GetStatus(rw http.ResponseWriter, r *http.Request) {
 	status, err := p.client.Status()
+	if err != nil {
+		err = utils.ErrChain(err)
+	}
 	utils.RenderQuery(rw, status, err)
 }
 
 // GetValidators returns the current validator set
 func (p Proxy) GetValidators(rw http.ResponseWriter, r *http.Request) {
 	vals, err := p.client.Validators()
+	if err != nil {
+		err = utils.ErrChain(err)
+	}
 	utils.RenderQuery(rw, vals, err)
 }
 
@@ -80,6 +205,9 @@ func (p Proxy) GetBlock(rw http.ResponseWriter, r *http.Request) {
 	h, err := strconv.Atoi(r.URL.Query().Get("height"))
 	if err == nil {
 		res, err = p.client.Block(h)
+		if err != nil {
+			err = utils.ErrChain(err)
+		}
 	}
 	utils.RenderQuery(rw, res, err)
 }
@@ -90,14 +218,18 @@ func (p Proxy) GetChain(rw http.ResponseWriter, r *http.Request) {
 	var res *ctypes.ResultBlockchainInfo
 	min, err := strconv.Atoi(r.URL.Query().Get("minHeight"))
 	if err == nil {
-		max, err := strconv.Atoi(r.URL.Query().Get("maxHeight"))
+		var max int
+		max, err = strconv.Atoi(r.URL.Query().Get("maxHeight"))
 		if err == nil {
 			if max-min < 0 {
-				err = errors.New("maxHeight must be greater than minHeight")
+				err = utils.BadRequest(errors.New("maxHeight must be greater than minHeight"))
 			} else if max-min > maxBlocks {
-				err = errors.Errorf("You cannot query more than %d blocks at once", maxBlocks)
+				err = utils.BadRequest(errors.Errorf("You cannot query more than %d blocks at once", maxBlocks))
 			} else {
 				res, err = p.client.BlockchainInfo(min, max)
+				if err != nil {
+					err = utils.ErrChain(err)
+				}
 			}
 		}
 	}
@@ -108,6 +240,7 @@ func (p Proxy) GetChain(rw http.ResponseWriter, r *http.Request) {
 func (p Proxy) AddChainRoutes(r *mux.Router) {
 	tndr := r.PathPrefix("/tndr").Subrouter()
 	tndr.HandleFunc("/tx", p.PostTransaction).Methods("POST")
+	tndr.HandleFunc("/tx/{hash}", p.app.GetTxStatus).Methods("GET")
 	tndr.HandleFunc("/status", p.GetStatus).Methods("GET")
 	tndr.HandleFunc("/validators", p.GetValidators).Methods("GET")
 	tndr.HandleFunc("/block", p.GetBlock).Methods("GET")
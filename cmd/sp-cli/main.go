@@ -2,9 +2,13 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -14,7 +18,11 @@ import (
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 	"gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/ethanfrey/signedpost/store"
 	"github.com/ethanfrey/signedpost/txn"
+	"github.com/ethanfrey/signedpost/utils"
+	"github.com/ethanfrey/signedpost/view"
+	"github.com/ethanfrey/tenderize/mom"
 	"github.com/ethanfrey/tenderize/sign"
 )
 
@@ -22,17 +30,100 @@ type txPost struct {
 	TX string `json:"tx"`
 }
 
+// txResult is the response of the server to a tx we sent
+type txResult struct {
+	ctypes.ResultBroadcastTx
+	Hash   string `json:"hash"`
+	Height uint64 `json:"height"`
+}
+
+// txStatus is the response of the server to /tndr/tx/{hash}
+type txStatus struct {
+	Status   string `json:"status"`
+	Height   uint64 `json:"height"`
+	CodeName string `json:"code_name"`
+	Log      string `json:"log"`
+	Data     []byte `json:"data"`
+}
+
 var (
 	app     = kingpin.New("sp-cli", "A simple command line client for the signed post tendermint app")
 	server  = app.Flag("server", "URL of signed post server").Default("http://localhost:54321").String()
-	keyFile = app.Flag("key", "File location for private key to sign with (generated if missing)").Required().String()
+	keyFile = app.Flag("key", "File location for private key to sign with (generated if missing)").String()
+	mode    = app.Flag("mode", "Wait until the tx is sent (async), passed CheckTx (sync) or is in a block (commit)").
+		Default("commit").Enum("async", "sync", "commit")
 
 	user = app.Command("account", "Create an account")
 	name = user.Arg("name", "The username for the account").Required().String()
 
+	profile        = app.Command("profile", "Set the profile of your account (replacing all fields)")
+	profileDisplay = profile.Flag("display-name", "The name to show, need not be unique").String()
+	profileBio     = profile.Flag("bio", "A short bio").String()
+	profileWebsite = profile.Flag("website", "Your website (http or https url)").String()
+	profileAvatar  = profile.Flag("avatar", "Image file to publish the sha256 of as avatar").String()
+
 	post    = app.Command("post", "Add a new post")
 	title   = post.Arg("title", "The title of the post").Required().String()
 	content = post.Arg("content", "The post content").Required().String()
+	replyTo = post.Flag("reply-to", "The hex id of the post this replies to").String()
+
+	edit        = app.Command("edit", "Add a new revision to one of your posts")
+	editNumber  = edit.Arg("number", "The number of the post in your account").Required().Int64()
+	editTitle   = edit.Arg("title", "The new title of the post").Required().String()
+	editContent = edit.Arg("content", "The new post content").Required().String()
+
+	retract       = app.Command("retract", "Retract one of your posts, leaving a signed tombstone")
+	retractNumber = retract.Arg("number", "The number of the post in your account").Required().Int64()
+	retractReason = retract.Arg("reason", "Why the post is retracted").Required().String()
+
+	rotate    = app.Command("rotate", "Move your account to a new key, the old key can no longer sign for it")
+	rotateKey = rotate.Arg("new-key", "File location for the new private key (generated if missing)").Required().String()
+
+	address = app.Command("address", "Print the address of your key, to add it to a multisig account")
+
+	multisig          = app.Command("multisig", "Create an account that needs a threshold of member keys to sign")
+	multisigName      = multisig.Arg("name", "The username for the account").Required().String()
+	multisigThreshold = multisig.Arg("threshold", "How many members must sign").Required().Int64()
+	multisigMembers   = multisig.Arg("members", "Hex addresses of the members (including yours)").Required().Strings()
+
+	msigPost      = app.Command("msig-post", "Add a new post to a multisig account")
+	msigAccount   = msigPost.Arg("account", "The hex id of the multisig account").Required().String()
+	msigTitle     = msigPost.Arg("title", "The title of the post").Required().String()
+	msigContent   = msigPost.Arg("content", "The post content").Required().String()
+	msigCosigners = msigPost.Flag("cosigner", "Key file of a member to sign with (repeat for each member)").Strings()
+
+	endorse        = app.Command("endorse", "Sign the post of another account")
+	endorsePost    = endorse.Arg("post", "The hex id of the post").Required().String()
+	endorseComment = endorse.Flag("comment", "An optional comment").String()
+
+	follow        = app.Command("follow", "Add the posts of another account to your feed")
+	followAccount = follow.Arg("account", "The hex id of the account").Required().String()
+
+	unfollow        = app.Command("unfollow", "Remove an account from your feed")
+	unfollowAccount = unfollow.Arg("account", "The hex id of the account").Required().String()
+
+	attest        = app.Command("attest", "Vouch for the real-world identity of another account")
+	attestAccount = attest.Arg("account", "The hex id of the account").Required().String()
+	attestClaim   = attest.Arg("claim", "The type of claim, eg. email or legal-name").Required().String()
+	attestValue   = attest.Arg("value", "The value you vouch for").Required().String()
+	attestExpires = attest.Flag("expires", "Block height the attestation expires at (0 = never)").Uint64()
+
+	revoke        = app.Command("revoke", "Revoke one of your attestations")
+	revokeAccount = revoke.Arg("account", "The hex id of the account").Required().String()
+	revokeClaim   = revoke.Arg("claim", "The type of claim").Required().String()
+
+	notarize      = app.Command("notarize", "Prove the existence of a file, without publishing it")
+	notarizeFile  = notarize.Arg("file", "The file to notarize").Required().String()
+	notarizeAlgo  = notarize.Flag("algo", "Hash algorithm (sha256 | sha512)").Default(txn.HashSHA256).String()
+	notarizeTitle = notarize.Flag("title", "An optional title for the document").String()
+
+	status     = app.Command("status", "Show if a tx is pending, committed or rejected")
+	statusHash = status.Arg("hash", "The hex hash printed when the tx was sent").Required().String()
+
+	verify        = app.Command("verify", "Verify a proof bundle (from /posts/{id}/proof) against the blockchain")
+	bundleFile    = verify.Arg("bundle", "The json file with the proof").Required().String()
+	verifyAppHash = verify.Flag("app-hash", "Hex app hash to verify against (offline), otherwise we query the server").String()
+	verifySigner  = verify.Flag("signer", "Hex account id that must have signed the post").String()
 )
 
 // ParseKey reads a keyfile or creates one if needed
@@ -40,7 +131,7 @@ func ParseKey(keyfile string) (crypto.PrivKey, error) {
 	// first, make sure we have a private key
 	var key crypto.PrivKey
 	var err error
-	if file, err := os.Open(*keyFile); err == nil {
+	if file, err := os.Open(keyfile); err == nil {
 		defer file.Close()
 		data, err := ioutil.ReadAll(file)
 		if err != nil {
@@ -52,7 +143,7 @@ func ParseKey(keyfile string) (crypto.PrivKey, error) {
 		}
 	} else {
 		key = crypto.GenPrivKeyEd25519()
-		outf, err := os.Create(*keyFile)
+		outf, err := os.Create(keyfile)
 		if err != nil {
 			return nil, errors.Wrap(err, "Creating key file")
 		}
@@ -65,24 +156,290 @@ func ParseKey(keyfile string) (crypto.PrivKey, error) {
 	return key, err
 }
 
+// HashFile creates a NotarizeAction with the digest of the file contents
+func HashFile(filename, algo string) (txn.NotarizeAction, error) {
+	tx := txn.NotarizeAction{Algorithm: algo}
+	var hasher hash.Hash
+	switch algo {
+	case txn.HashSHA256:
+		hasher = sha256.New()
+	case txn.HashSHA512:
+		hasher = sha512.New()
+	default:
+		return tx, errors.Errorf("Unknown hash algorithm: %s", algo)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return tx, errors.Wrap(err, "Opening file")
+	}
+	defer file.Close()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return tx, errors.Wrap(err, "Reading file")
+	}
+	tx.Digest = hasher.Sum(nil)
+	return tx, nil
+}
+
+// ResponseError returns the reason the server gave for a failed request,
+// or just the status if the body is not a json error
+func ResponseError(resp *http.Response) error {
+	var res utils.ErrorResponse
+	err := json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil || res.Error.Message == "" {
+		return errors.Errorf("Bad HTTP response: %d", resp.StatusCode)
+	}
+	return errors.Errorf("%s (%d %s)", res.Error.Message, resp.StatusCode, res.Error.Code)
+}
+
+// FetchAccount queries the server for the account this key controls
+// (which may have been created by an earlier key)
+func FetchAccount(pub crypto.PubKey) (*view.Account, error) {
+	return fetchAccount("/signers/" + hex.EncodeToString(pub.Address()))
+}
+
+// fetchAccount gets the account from this path of the REST API
+func fetchAccount(path string) (*view.Account, error) {
+	resp, err := http.Get(*server + path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Fetching account")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, errors.Wrap(ResponseError(resp), "No account for this key")
+	}
+
+	var acct view.Account
+	err = json.NewDecoder(resp.Body).Decode(&acct)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing account")
+	}
+	return &acct, nil
+}
+
+// NextSequence queries the server for the account of this key,
+// and returns the sequence to sign the next tx with
+func NextSequence(pub crypto.PubKey) (int64, error) {
+	acct, err := FetchAccount(pub)
+	if err != nil {
+		return 0, err
+	}
+	return acct.Sequence + 1, nil
+}
+
+// MultisigPost creates a post for the multisig account with this (hex) id,
+// signed by all the cosigners
+func MultisigPost(account, title, content string, cosigners []string) (tx txn.MultisigAction, err error) {
+	acct, err := fetchAccount("/accounts/" + account)
+	if err != nil {
+		return tx, err
+	}
+	id, err := parseAccountID(acct.ID)
+	if err != nil {
+		return tx, err
+	}
+	post := txn.AddPostAction{Title: title, Content: content, Sequence: acct.Sequence + 1}
+	tx, err = txn.NewMultisigAction(id, post)
+	if err != nil {
+		return tx, err
+	}
+	for _, file := range cosigners {
+		key, err := ParseKey(file)
+		if err != nil {
+			return tx, err
+		}
+		err = tx.AddSignature(key)
+		if err != nil {
+			return tx, err
+		}
+	}
+	return tx, nil
+}
+
+// parseAccountID returns the raw account id from the hex id used in the REST API
+func parseAccountID(hexID string) ([]byte, error) {
+	idBytes, err := hex.DecodeString(hexID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing account id")
+	}
+	id, err := mom.KeyFromBytes(idBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing account id")
+	}
+	acctKey, ok := id.(store.AccountKey)
+	if !ok {
+		return nil, errors.New("Not an account id")
+	}
+	return acctKey.ID, nil
+}
+
+// RotateKey creates the tx to move the account of key to the (countersigning) newKey
+func RotateKey(key, newKey crypto.PrivKey) (tx txn.RotateKeyAction, err error) {
+	acct, err := FetchAccount(key.PubKey())
+	if err != nil {
+		return tx, err
+	}
+	id, err := parseAccountID(acct.ID)
+	if err != nil {
+		return tx, err
+	}
+	tx.Sequence = acct.Sequence + 1
+	err = tx.CounterSign(id, newKey)
+	return tx, err
+}
+
 func main() {
 	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
 
+	// verify works offline and needs no key
+	if cmd == verify.FullCommand() {
+		os.Exit(Verify(*bundleFile, *verifyAppHash, *verifySigner))
+	}
+	if cmd == status.FullCommand() {
+		PrintTxStatus(*statusHash)
+		return
+	}
+
 	// make sure we have a key to sign
+	if *keyFile == "" {
+		kingpin.Fatalf("--key is required to sign transactions\n")
+	}
 	key, err := ParseKey(*keyFile)
 	if err != nil {
 		kingpin.Fatalf("Key error: %+v\n", err)
 	}
 
+	if cmd == address.FullCommand() {
+		fmt.Println(hex.EncodeToString(key.PubKey().Address()))
+		return
+	}
+
 	// generate and sign transaction based on input
 	var data []byte
 	switch cmd {
 	case user.FullCommand():
 		tx := txn.CreateAccountAction{Name: *name}
 		data, err = sign.Send(tx, key)
+	case profile.FullCommand():
+		tx := txn.UpdateProfileAction{DisplayName: *profileDisplay, Bio: *profileBio, Website: *profileWebsite}
+		if *profileAvatar != "" {
+			var hashed txn.NotarizeAction
+			hashed, err = HashFile(*profileAvatar, txn.HashSHA256)
+			tx.AvatarHash = hashed.Digest
+		}
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
 	case post.FullCommand():
 		tx := txn.AddPostAction{Title: *title, Content: *content}
-		data, err = sign.Send(tx, key)
+		tx.Parent, err = hex.DecodeString(*replyTo)
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case edit.FullCommand():
+		tx := txn.EditPostAction{Number: *editNumber, Title: *editTitle, Content: *editContent}
+		tx.Sequence, err = NextSequence(key.PubKey())
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case retract.FullCommand():
+		tx := txn.RetractPostAction{Number: *retractNumber, Reason: *retractReason}
+		tx.Sequence, err = NextSequence(key.PubKey())
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case rotate.FullCommand():
+		var newKey crypto.PrivKey
+		var tx txn.RotateKeyAction
+		newKey, err = ParseKey(*rotateKey)
+		if err == nil {
+			tx, err = RotateKey(key, newKey)
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case multisig.FullCommand():
+		tx := txn.CreateMultisigAction{Name: *multisigName, Threshold: *multisigThreshold}
+		for _, m := range *multisigMembers {
+			var addr []byte
+			addr, err = hex.DecodeString(m)
+			if err != nil {
+				break
+			}
+			tx.Members = append(tx.Members, addr)
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case msigPost.FullCommand():
+		var tx txn.MultisigAction
+		tx, err = MultisigPost(*msigAccount, *msigTitle, *msigContent, *msigCosigners)
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case endorse.FullCommand():
+		tx := txn.EndorsePostAction{Comment: *endorseComment}
+		tx.Post, err = hex.DecodeString(*endorsePost)
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case follow.FullCommand():
+		var tx txn.FollowAction
+		tx.Account, err = parseAccountID(*followAccount)
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case unfollow.FullCommand():
+		var tx txn.UnfollowAction
+		tx.Account, err = parseAccountID(*unfollowAccount)
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case attest.FullCommand():
+		tx := txn.AttestIdentityAction{Claim: *attestClaim, Value: *attestValue, ExpiresBlock: *attestExpires}
+		tx.Subject, err = parseAccountID(*attestAccount)
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case revoke.FullCommand():
+		tx := txn.RevokeAttestationAction{Claim: *revokeClaim}
+		tx.Subject, err = parseAccountID(*revokeAccount)
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			data, err = sign.Send(tx, key)
+		}
+	case notarize.FullCommand():
+		var tx txn.NotarizeAction
+		tx, err = HashFile(*notarizeFile, *notarizeAlgo)
+		if err == nil {
+			tx.Sequence, err = NextSequence(key.PubKey())
+		}
+		if err == nil {
+			tx.Title = *notarizeTitle
+			fmt.Printf("Digest: %s\n", hex.EncodeToString(tx.Digest))
+			data, err = sign.Send(tx, key)
+		}
 	}
 	if err != nil {
 		kingpin.Fatalf("Creating transaction: %+v\n", err)
@@ -98,7 +455,7 @@ func main() {
 	}
 
 	// actually post to the server
-	endpoint := *server + "/tndr/tx"
+	endpoint := *server + "/tndr/tx?mode=" + *mode
 	client := http.Client{}
 	resp, err := client.Post(endpoint, "application/json", bytes.NewBuffer(out))
 	if err != nil {
@@ -107,15 +464,52 @@ func main() {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		kingpin.Fatalf("Bad HTTP response: %d\n", resp.StatusCode)
+		kingpin.Fatalf("%v\n", ResponseError(resp))
 	}
 
 	// now we can print what happened!
-	var msg ctypes.ResultBroadcastTx
+	var msg txResult
 	err = json.NewDecoder(resp.Body).Decode(&msg)
 	if err != nil {
 		kingpin.Fatalf("Parse error tx response: %v\n", err)
 	}
 	fmt.Printf("Log: %s\n", msg.Log)
-	fmt.Printf("ID: %s\n", hex.EncodeToString(msg.Data))
+	fmt.Printf("Hash: %s\n", msg.Hash)
+	if msg.Height > 0 {
+		fmt.Printf("Height: %d\n", msg.Height)
+	}
+	// the id is only known once the tx is applied
+	if *mode != "async" {
+		fmt.Printf("ID: %s\n", hex.EncodeToString(msg.Data))
+	}
+}
+
+// PrintTxStatus asks the server what happened to the tx with this hash
+func PrintTxStatus(hash string) {
+	resp, err := http.Get(*server + "/tndr/tx/" + hash)
+	if err != nil {
+		kingpin.Fatalf("HTTP Error: %v\n", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		kingpin.Fatalf("%v\n", ResponseError(resp))
+	}
+
+	var st txStatus
+	err = json.NewDecoder(resp.Body).Decode(&st)
+	if err != nil {
+		kingpin.Fatalf("Parse error status response: %v\n", err)
+	}
+	fmt.Printf("Status: %s\n", st.Status)
+	if st.Height > 0 {
+		fmt.Printf("Height: %d\n", st.Height)
+	}
+	if st.CodeName != "" {
+		fmt.Printf("Reason: %s: %s\n", st.CodeName, st.Log)
+	} else if st.Log != "" {
+		fmt.Printf("Note: %s\n", st.Log)
+	}
+	if len(st.Data) > 0 {
+		fmt.Printf("ID: %s\n", hex.EncodeToString(st.Data))
+	}
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	merkle "github.com/tendermint/go-merkle"
+
+	"github.com/ethanfrey/signedpost"
+	"github.com/ethanfrey/signedpost/txn"
+	"github.com/ethanfrey/tenderize/sign"
+)
+
+// testServer runs the query api of a fresh app, and points the cli at it
+func testServer(t *testing.T) (*signedpost.Application, func()) {
+	app := signedpost.NewApp(merkle.NewIAVLTree(0, nil))
+	r := mux.NewRouter()
+	app.AddQueryRoutes(r)
+	srv := httptest.NewServer(r)
+	old := *server
+	*server = srv.URL
+	return app, func() {
+		*server = old
+		srv.Close()
+	}
+}
+
+// commitTx runs the tx in a block of its own, and returns the result data
+func commitTx(t *testing.T, app *signedpost.Application, height uint64, tx []byte) []byte {
+	app.BeginBlock(height)
+	res := app.AppendTx(tx)
+	require.False(t, res.IsErr(), res.Error())
+	app.EndBlock(height)
+	app.Commit()
+	return res.Data
+}
+
+func TestParseKey(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	dir, err := ioutil.TempDir("", "sp-cli")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	// each file has its own key, which is created once and then loaded
+	first, err := ParseKey(filepath.Join(dir, "first.key"))
+	require.Nil(err, "%+v", err)
+	second, err := ParseKey(filepath.Join(dir, "second.key"))
+	require.Nil(err, "%+v", err)
+	assert.NotEqual(first.PubKey().Address(), second.PubKey().Address())
+	again, err := ParseKey(filepath.Join(dir, "first.key"))
+	require.Nil(err, "%+v", err)
+	assert.Equal(first.PubKey().Address(), again.PubKey().Address())
+}
+
+func TestRotateKey(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	dir, err := ioutil.TempDir("", "sp-cli")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	app, done := testServer(t)
+	defer done()
+
+	key, err := ParseKey(filepath.Join(dir, "old.key"))
+	require.Nil(err, "%+v", err)
+	data, err := sign.Send(txn.CreateAccountAction{Name: "Rotating"}, key)
+	require.Nil(err, "%+v", err)
+	commitTx(t, app, 1, data)
+	acct, err := FetchAccount(key.PubKey())
+	require.Nil(err, "%+v", err)
+
+	// as the cli does for `rotate new.key`
+	newKey, err := ParseKey(filepath.Join(dir, "new.key"))
+	require.Nil(err, "%+v", err)
+	tx, err := RotateKey(key, newKey)
+	require.Nil(err, "%+v", err)
+	data, err = sign.Send(tx, key)
+	require.Nil(err, "%+v", err)
+	commitTx(t, app, 2, data)
+
+	// the account now belongs to the new key
+	moved, err := FetchAccount(newKey.PubKey())
+	require.Nil(err, "%+v", err)
+	assert.Equal(acct.ID, moved.ID)
+	_, err = FetchAccount(key.PubKey())
+	assert.NotNil(err)
+}
+
+func TestMultisigPost(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	dir, err := ioutil.TempDir("", "sp-cli")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	app, done := testServer(t)
+	defer done()
+
+	key, err := ParseKey(filepath.Join(dir, "alice.key"))
+	require.Nil(err, "%+v", err)
+	cosigners := []string{filepath.Join(dir, "bob.key"), filepath.Join(dir, "carol.key")}
+	members := [][]byte{key.PubKey().Address()}
+	for _, file := range cosigners {
+		member, err := ParseKey(file)
+		require.Nil(err, "%+v", err)
+		members = append(members, member.PubKey().Address())
+	}
+	data, err := sign.Send(txn.CreateMultisigAction{Name: "Board", Threshold: 2, Members: members}, key)
+	require.Nil(err, "%+v", err)
+	acctKey := commitTx(t, app, 1, data)
+
+	// as the cli does for `msig-post --cosigner bob.key --cosigner carol.key`
+	tx, err := MultisigPost(hex.EncodeToString(acctKey), "Minutes", "We met", cosigners)
+	require.Nil(err, "%+v", err)
+	signers, err := tx.Signers()
+	require.Nil(err, "%+v", err)
+	assert.Equal(members[1:], signers)
+	assert.NotContains(signers, key.PubKey().Address())
+
+	// which is enough for the threshold, whoever sends it
+	data, err = sign.Send(tx, key)
+	require.Nil(err, "%+v", err)
+	commitTx(t, app, 2, data)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	merkle "github.com/tendermint/go-merkle"
+
+	"github.com/ethanfrey/signedpost/store"
+	"github.com/ethanfrey/signedpost/view"
+	"github.com/ethanfrey/tenderize/mom"
+	wutil "github.com/ethanfrey/tenderize/wire"
+)
+
+// exit codes for verify, so scripts can act on the verdict
+const (
+	exitVerified = 0
+	exitError    = 1
+	exitInvalid  = 2
+)
+
+// blockHeader is the part of /tndr/block we need to check the app hash
+type blockHeader struct {
+	Block struct {
+		Header struct {
+			Height  int    `json:"height"`
+			AppHash []byte `json:"app_hash"`
+		} `json:"header"`
+	} `json:"block"`
+}
+
+// Bundle is a parsed proof, ready to verify
+type Bundle struct {
+	Key    []byte
+	Value  []byte
+	Proof  merkle.IAVLProof
+	Height uint64
+	Model  mom.Model
+}
+
+// LoadBundle reads the output of /posts/{id}/proof (or /accounts/{id}/proof) from a file
+func LoadBundle(filename string) (*Bundle, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "Opening bundle")
+	}
+	defer file.Close()
+
+	var proof view.Proof
+	err = json.NewDecoder(file).Decode(&proof)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing bundle")
+	}
+
+	res := Bundle{Height: proof.Height}
+	res.Key, err = hex.DecodeString(proof.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Decoding key")
+	}
+	res.Value, err = hex.DecodeString(proof.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "Decoding value")
+	}
+	pbytes, err := hex.DecodeString(proof.Proof)
+	if err != nil {
+		return nil, errors.Wrap(err, "Decoding proof")
+	}
+	err = wutil.FromBinary(pbytes, &res.Proof)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing proof")
+	}
+	res.Model, err = mom.ModelFromBytes(res.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing model")
+	}
+	return &res, nil
+}
+
+// MatchesModel is true if the proven key is the key of the proven model,
+// so the proof is really about this post (or account)
+func (b Bundle) MatchesModel() bool {
+	key, err := mom.KeyToBytes(b.Model.Key())
+	return err == nil && bytes.Equal(key, b.Key)
+}
+
+// Signer returns the account that signed this model, as named in the proven key
+func (b Bundle) Signer() (store.AccountKey, error) {
+	key, err := mom.KeyFromBytes(b.Key)
+	if err != nil {
+		return store.AccountKey{}, errors.Wrap(err, "Parsing key")
+	}
+	switch k := key.(type) {
+	case store.PostKey:
+		if acct, ok := k.Account.(store.AccountKey); ok {
+			return acct, nil
+		}
+		return store.AccountKey{}, errors.New("Post key without an account")
+	case store.AccountKey:
+		return k, nil
+	}
+	return store.AccountKey{}, errors.Errorf("Unknown key: %T", key)
+}
+
+// parseSigner reads the account id we expect, as the server renders it
+func parseSigner(id string) (store.AccountKey, error) {
+	data, err := hex.DecodeString(id)
+	if err != nil {
+		return store.AccountKey{}, errors.Wrap(err, "Invalid signer")
+	}
+	key, err := mom.KeyFromBytes(data)
+	if err != nil {
+		return store.AccountKey{}, errors.Wrap(err, "Invalid signer")
+	}
+	acct, ok := key.(store.AccountKey)
+	if !ok {
+		return store.AccountKey{}, errors.New("Invalid signer, not an account id")
+	}
+	return acct, nil
+}
+
+// FetchAppHash gets the app hash after the given height from the server.
+// This is stored in the header of the following block
+func FetchAppHash(server string, height uint64) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/tndr/block?height=%d", server, height+1)
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "Fetching block")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, ResponseError(resp)
+	}
+
+	var block blockHeader
+	err = json.NewDecoder(resp.Body).Decode(&block)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parsing block")
+	}
+	return block.Block.Header.AppHash, nil
+}
+
+// Verify checks the bundle, prints a verdict and returns the exit code
+func Verify(filename, appHash, signer string) int {
+	bundle, err := LoadBundle(filename)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return exitError
+	}
+
+	// get the app hash from the command line if given, otherwise from the chain
+	var hash []byte
+	if appHash != "" {
+		hash, err = hex.DecodeString(appHash)
+	} else {
+		hash, err = FetchAppHash(*server, bundle.Height)
+	}
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return exitError
+	}
+
+	owner, err := bundle.Signer()
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return exitError
+	}
+	ownerID, err := mom.KeyToBytes(owner)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		return exitError
+	}
+	var expected *store.AccountKey
+	if signer != "" {
+		want, err := parseSigner(signer)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			return exitError
+		}
+		expected = &want
+	}
+
+	fmt.Printf("Model:    %T\n", bundle.Model)
+	if post, ok := bundle.Model.(store.Post); ok {
+		fmt.Printf("Title:    %s\n", post.Title)
+		fmt.Printf("Block:    %d\n", post.PublishedBlock)
+	}
+	fmt.Printf("Signer:   %x\n", ownerID)
+	fmt.Printf("Height:   %d\n", bundle.Height)
+	fmt.Printf("App Hash: %X\n", hash)
+
+	if !bytes.Equal(bundle.Proof.RootHash, hash) {
+		fmt.Println("INVALID: proof is not for this app hash")
+		return exitInvalid
+	}
+	if !bundle.Proof.Verify(bundle.Key, bundle.Value, hash) {
+		fmt.Println("INVALID: merkle proof does not match the data")
+		return exitInvalid
+	}
+	if !bundle.MatchesModel() {
+		fmt.Println("INVALID: proven key is not the key of the data")
+		return exitInvalid
+	}
+	if expected != nil && !bytes.Equal(expected.ID, owner.ID) {
+		fmt.Printf("INVALID: signed by %x, not %s\n", ownerID, signer)
+		return exitInvalid
+	}
+	fmt.Println("VERIFIED")
+	return exitVerified
+}
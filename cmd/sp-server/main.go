@@ -1,19 +1,70 @@
 package main
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 
+	dbm "github.com/tendermint/go-db"
 	"github.com/tendermint/go-merkle"
+	"github.com/tendermint/tendermint/blockchain"
 	"github.com/tendermint/tmsp/server"
 
 	"github.com/ethanfrey/signedpost"
 )
 
+// cacheSize is the number of merkle nodes to keep in memory when using a db
+const cacheSize = 10000
+
+// MakeApp creates an in-memory app, or one persisted to leveldb if dbPath is set
+func MakeApp(dbPath string) (*signedpost.Application, error) {
+	if dbPath == "" {
+		return signedpost.NewApp(merkle.NewIAVLTree(0, nil)), nil
+	}
+	db, err := dbm.NewLevelDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	return signedpost.LoadApp(db, cacheSize)
+}
+
+// DefaultDataDir is where tendermint keeps its blocks, $TMROOT/data (or ~/.tendermint/data)
+func DefaultDataDir() string {
+	root := os.Getenv("TMROOT")
+	if root == "" {
+		root = path.Join(os.Getenv("HOME"), ".tendermint")
+	}
+	return path.Join(root, "data")
+}
+
+// ReplayChain applies all blocks in tendermint's block store (in dataDir),
+// that the app has not yet seen.  Tendermint must not be running.
+// Without a block store, there is nothing to replay on a new app, but an app that
+// resumes from a commit cannot know if it missed blocks, so that is an error
+func ReplayChain(app *signedpost.Application, dataDir string) error {
+	dir := path.Join(dataDir, "blockstore.db")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if last := app.LastCommit().Height; last > 0 {
+			return fmt.Errorf("No block store in %s to replay from, but the app resumes from height %d: "+
+				"set --replay to tendermint's data dir", dataDir, last)
+		}
+		return nil
+	}
+	db, err := dbm.NewLevelDB(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return app.Replay(blockchain.NewBlockStore(db))
+}
+
 // MakeServer creates an http server
 func MakeServer(listen string, app *signedpost.Application, proxy signedpost.Proxy) *http.Server {
 	r := mux.NewRouter()
@@ -34,16 +85,48 @@ func main() {
 	protoPtr := flag.String("tmsp", "socket", "socket | grpc")
 	rpcPtr := flag.String("rpc", "localhost:46657", "Address of tendermint core rpc server")
 	servePtr := flag.String("http", ":54321", "Port to serve the custom http application")
+	dbPtr := flag.String("db", "", "Directory for the leveldb store (in-memory if empty)")
+	historyPtr := flag.Uint64("history", 100, "Number of past blocks that can be queried with ?height=")
+	trustPtr := flag.String("trust", "", "Comma-separated (hex) ids of the accounts whose attestations we trust")
+	replayPtr := flag.String("replay", DefaultDataDir(), "Tendermint data dir to replay missing blocks from before starting (with --db)")
 	flag.Parse()
 
-	// these should come from command-line
-	tree := merkle.NewIAVLTree(0, nil)
-
-	app := signedpost.NewApp(tree)
-	proxy := signedpost.NewProxy(*rpcPtr)
+	app, err := MakeApp(*dbPtr)
+	if err != nil {
+		fmt.Printf("Loading app failed: %+v\n", err)
+		return
+	}
+	app.KeepHistory(*historyPtr)
+	if *trustPtr != "" {
+		var ids [][]byte
+		for _, id := range strings.Split(*trustPtr, ",") {
+			raw, err := hex.DecodeString(strings.TrimSpace(id))
+			if err != nil {
+				fmt.Printf("Invalid attester %q: %+v\n", id, err)
+				return
+			}
+			ids = append(ids, raw)
+		}
+		err = app.TrustAttesters(ids)
+		if err != nil {
+			fmt.Printf("Invalid attester: %+v\n", err)
+			return
+		}
+	}
+	// the app must catch up with the chain before tendermint sends it the next block
+	if *dbPtr != "" {
+		err = ReplayChain(app, *replayPtr)
+		if err != nil {
+			fmt.Printf("Replay failed: %+v\n", err)
+			return
+		}
+	}
+	fmt.Println("App info:", app.Info())
+	proxy := signedpost.NewProxy(*rpcPtr, app)
+	app.FetchBlockTimes(proxy)
 
 	// start tmsp server
-	_, err := server.NewServer(*tmspPtr, *protoPtr, app)
+	_, err = server.NewServer(*tmspPtr, *protoPtr, app)
 	if err != nil {
 		fmt.Printf("TMSP server failed: %+v\n", err)
 		return
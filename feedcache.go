@@ -0,0 +1,52 @@
+package signedpost
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethanfrey/signedpost/view"
+)
+
+// feedCache keeps the rendered global atom feed of one version of the state, as reading it
+// goes through all accounts. Feed readers poll it, mostly with nothing new since the last block.
+// A feed is also rendered for each base url and with or without retracted posts,
+// so we keep one per variant of the request
+type feedCache struct {
+	mtx     sync.Mutex
+	version string // snapshotVersion of the feeds
+	feeds   map[string]*view.AtomFeed
+}
+
+func newFeedCache() *feedCache {
+	return &feedCache{feeds: map[string]*view.AtomFeed{}}
+}
+
+// snapshotVersion identifies the state a query reads from, it changes with every commit
+func snapshotVersion(snap view.Snapshot) string {
+	return fmt.Sprintf("%d-%X", snap.Height, snap.Hash)
+}
+
+func feedVariant(base string, hideRetracted bool) string {
+	return fmt.Sprintf("%s %t", base, hideRetracted)
+}
+
+// get returns the feed rendered from this snapshot, nil if we have none
+func (c *feedCache) get(snap view.Snapshot, base string, hideRetracted bool) *view.AtomFeed {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.version != snapshotVersion(snap) {
+		return nil
+	}
+	return c.feeds[feedVariant(base, hideRetracted)]
+}
+
+// put stores a feed rendered from this snapshot, and forgets those of other versions
+func (c *feedCache) put(snap view.Snapshot, base string, hideRetracted bool, feed *view.AtomFeed) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if version := snapshotVersion(snap); c.version != version {
+		c.version = version
+		c.feeds = map[string]*view.AtomFeed{}
+	}
+	c.feeds[feedVariant(base, hideRetracted)] = feed
+}
@@ -0,0 +1,90 @@
+package signedpost
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ethanfrey/signedpost/utils"
+	"github.com/ethanfrey/signedpost/view"
+	dbm "github.com/tendermint/go-db"
+	merkle "github.com/tendermint/go-merkle"
+)
+
+// defaultHistory is the number of past commits we can query by default
+const defaultHistory = 100
+
+// history keeps read-only snapshots of the last commits, so we can query the state
+// at a past height. If we have a db, the roots are also stored there, so they can be
+// reloaded after a restart (the merkle nodes themselves are never removed from the db).
+type history struct {
+	mtx       sync.RWMutex
+	snapshots map[uint64]view.Snapshot
+	keep      uint64
+	db        dbm.DB
+}
+
+func newHistory(keep uint64, db dbm.DB) *history {
+	return &history{
+		snapshots: map[uint64]view.Snapshot{},
+		keep:      keep,
+		db:        db,
+	}
+}
+
+// add stores the snapshot of a new commit, and forgets the one that fell out of the window
+func (h *history) add(snap view.Snapshot) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.snapshots[snap.Height] = snap
+	if snap.Height >= h.keep {
+		delete(h.snapshots, snap.Height-h.keep)
+	}
+
+	if h.db != nil {
+		root := CommitState{Height: snap.Height, Hash: snap.Hash}
+		err := root.saveRoot(h.db)
+		if err != nil {
+			return err
+		}
+		if snap.Height >= h.keep {
+			h.db.Delete(rootKey(snap.Height - h.keep))
+		}
+	}
+	return nil
+}
+
+// get returns the snapshot at this height, which must be in the window
+// of the latest height. It may be loaded from the db if we restarted since.
+func (h *history) get(height, latest uint64) (view.Snapshot, error) {
+	if height > latest {
+		return view.Snapshot{}, utils.BadRequest(errors.Errorf("Height %d not yet committed", height))
+	}
+	if latest-height >= h.keep {
+		return view.Snapshot{}, utils.BadRequest(errors.Errorf("Height %d no longer available, only the last %d are kept", height, h.keep))
+	}
+
+	h.mtx.RLock()
+	snap, ok := h.snapshots[height]
+	h.mtx.RUnlock()
+	if ok {
+		return snap, nil
+	}
+
+	if h.db == nil {
+		return view.Snapshot{}, utils.BadRequest(errors.Errorf("Height %d not available", height))
+	}
+	root, err := loadRoot(h.db, height)
+	if err != nil {
+		return view.Snapshot{}, err
+	}
+	tree := merkle.NewIAVLTree(0, h.db)
+	tree.Load(root.Hash)
+	snap = view.Snapshot{Tree: tree, Height: root.Height, Hash: root.Hash}
+
+	h.mtx.Lock()
+	h.snapshots[height] = snap
+	h.mtx.Unlock()
+	return snap, nil
+}
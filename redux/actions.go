@@ -1,6 +1,9 @@
 package redux
 
 import (
+	"bytes"
+	"fmt"
+
 	"github.com/ethanfrey/signedpost/store"
 	"github.com/ethanfrey/signedpost/txn"
 	"github.com/ethanfrey/tenderize/mom"
@@ -11,31 +14,50 @@ import (
 // CreateAccount creates a new account based on the signing public key
 func (ctx *Service) CreateAccount(tx txn.CreateAccountAction, signer crypto.PubKey) tmsp.Result {
 	if signer == nil {
-		return tmsp.NewError(tmsp.CodeType_Unauthorized, "Must sign transaction")
+		return tmsp.NewError(CodeUnsigned, "Must sign transaction")
+	}
+	if err := tx.ValidateName(); err != nil {
+		return tmsp.NewError(CodeInvalidName, err.Error())
 	}
 
 	// make sure none with this name or pk already....
 	exists, err := store.FindAccount(ctx.GetDB(), signer)
 	if err != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, err.Error())
+		return storageError(err)
 	}
 	if exists != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseDuplicateAddress,
+		return tmsp.NewError(CodeKeyHasAccount,
 			"Account exists for this public key")
 	}
+	// a key that was rotated away from cannot reclaim the account id
+	used, err := store.AccountExists(ctx.GetDB(), signer.Address())
+	if err != nil {
+		return storageError(err)
+	}
+	if used {
+		return tmsp.NewError(CodeKeyWasUsed,
+			"Account id was already used by this public key")
+	}
 
-	matches, err := store.ListAccounts(ctx.GetDB(), store.AccountMatchesName(tx.Name))
+	taken, err := store.FindAccountByName(ctx.GetDB(), tx.Name)
 	if err != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, err.Error())
+		return storageError(err)
 	}
-	if len(matches) > 0 {
-		return tmsp.NewError(tmsp.CodeType_BaseDuplicateAddress,
+	if taken != nil {
+		return tmsp.NewError(CodeNameTaken,
 			"Account name already taken")
 	}
 
 	// all safe, go save it
 	account := store.NewAccount(signer, tx.Name)
 	mom.Save(ctx.GetDB(), account)
+	err = store.IndexAccount(ctx.GetDB(), account)
+	if err == nil {
+		err = store.IndexSigner(ctx.GetDB(), account)
+	}
+	if err != nil {
+		return storageError(err)
+	}
 	// return the new pk as response
 	key, _ := mom.KeyToBytes(account.Key())
 	return tmsp.NewResultOK(key, "")
@@ -43,18 +65,33 @@ func (ctx *Service) CreateAccount(tx txn.CreateAccountAction, signer crypto.PubK
 
 // AppendPost adds a post to an existing account
 func (ctx *Service) AppendPost(tx txn.AddPostAction, signer crypto.PubKey) tmsp.Result {
-	if signer == nil {
-		return tmsp.NewError(tmsp.CodeType_Unauthorized, "Must sign transaction")
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
 	}
+	return ctx.appendPost(tx, acct)
+}
 
-	// make sure we can find account for this user
-	acct, err := store.FindAccount(ctx.GetDB(), signer)
-	if err != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, err.Error())
+// appendPost runs the action for this (already authorized) account
+func (ctx *Service) appendPost(tx txn.AddPostAction, acct *store.Account) tmsp.Result {
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
 	}
-	if acct == nil {
-		return tmsp.NewError(tmsp.CodeType_BaseUnknownAddress,
-			"No account exists for this public key")
+
+	// a reply must point to an existing post
+	var parent *store.Post
+	if len(tx.Parent) > 0 {
+		var res tmsp.Result
+		parent, res = ctx.loadPost(tx.Parent)
+		if res.IsErr() {
+			return res
+		}
+		if res := ctx.checkNotRetracted(*parent); res.Code == CodePostRetracted {
+			return tmsp.NewError(CodePostRetracted, "Cannot reply to a retracted post")
+		} else if res.IsErr() {
+			return res
+		}
 	}
 
 	// fill out other info...
@@ -66,19 +103,744 @@ func (ctx *Service) AppendPost(tx txn.AddPostAction, signer crypto.PubKey) tmsp.
 		Number:         num,
 		PublishedBlock: ctx.GetHeight(),
 	}
-	_, err = mom.Save(ctx.GetDB(), post)
+	if parent != nil {
+		post.Parent = parent.Key()
+	}
+	_, err := mom.Save(ctx.GetDB(), post)
 	if err != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, err.Error())
+		return storageError(err)
 	}
 
 	// if saved, we must update account
 	acct.EntryCount = num
 	_, err = mom.Save(ctx.GetDB(), *acct)
 	if err != nil {
-		return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, err.Error())
+		return storageError(err)
+	}
+
+	// and index the reply under the parent (which is left as it was signed)
+	if parent != nil {
+		count, err := store.CountReplies(ctx.GetDB(), parent.Key())
+		if err != nil {
+			return storageError(err)
+		}
+		reply := store.Reply{Parent: parent.Key(), Number: count + 1, Post: post.Key()}
+		_, err = mom.Save(ctx.GetDB(), reply)
+		if err != nil {
+			return storageError(err)
+		}
 	}
 
 	// return the post key as response
 	key, _ := mom.KeyToBytes(post.Key())
 	return tmsp.NewResultOK(key, "")
 }
+
+// EditPost adds a new revision to a post, only the account that wrote the post can edit it
+func (ctx *Service) EditPost(tx txn.EditPostAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.editPost(tx, acct)
+}
+
+// editPost runs the action for this (already authorized) account
+func (ctx *Service) editPost(tx txn.EditPostAction, acct *store.Account) tmsp.Result {
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	// we only look for posts under the signer's account, so no one else can edit them
+	model, err := mom.Load(ctx.GetDB(), store.PostsForAccount(*acct, tx.Number))
+	if err != nil {
+		return storageError(err)
+	}
+	if model == nil {
+		return tmsp.NewError(CodeNoPost,
+			"No post with this number for this account")
+	}
+	post := model.(store.Post)
+	if res := ctx.checkNotRetracted(post); res.IsErr() {
+		return res
+	}
+
+	count, err := store.CountRevisions(ctx.GetDB(), post)
+	if err != nil {
+		return storageError(err)
+	}
+	rev := store.PostRevision{
+		Post:           post.Key(),
+		Revision:       count + 1,
+		Title:          tx.Title,
+		Content:        tx.Content,
+		PublishedBlock: ctx.GetHeight(),
+	}
+	_, err = mom.Save(ctx.GetDB(), rev)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// if saved, we must update the account sequence (the original post is left as it was signed)
+	_, err = mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the revision key as response
+	key, _ := mom.KeyToBytes(rev.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// RetractPost leaves a tombstone for a post, only the account that wrote the post can retract it
+func (ctx *Service) RetractPost(tx txn.RetractPostAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.retractPost(tx, acct)
+}
+
+// retractPost runs the action for this (already authorized) account
+func (ctx *Service) retractPost(tx txn.RetractPostAction, acct *store.Account) tmsp.Result {
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	// we only look for posts under the signer's account, so no one else can retract them
+	model, err := mom.Load(ctx.GetDB(), store.PostsForAccount(*acct, tx.Number))
+	if err != nil {
+		return storageError(err)
+	}
+	if model == nil {
+		return tmsp.NewError(CodeNoPost,
+			"No post with this number for this account")
+	}
+	post := model.(store.Post)
+	if res := ctx.checkNotRetracted(post); res.Code == CodePostRetracted {
+		return tmsp.NewError(CodeAlreadyRetracted,
+			"Post already retracted")
+	} else if res.IsErr() {
+		return res
+	}
+
+	tomb := store.Retraction{
+		Post:           post.Key(),
+		Reason:         tx.Reason,
+		PublishedBlock: ctx.GetHeight(),
+	}
+	_, err = mom.Save(ctx.GetDB(), tomb)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// if saved, we must update account sequence (the post stays as it was, so it can still be proven)
+	_, err = mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the retraction key as response
+	key, _ := mom.KeyToBytes(tomb.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// UpdateProfile replaces the profile of the signer's account
+func (ctx *Service) UpdateProfile(tx txn.UpdateProfileAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.updateProfile(tx, acct)
+}
+
+// updateProfile runs the action for this (already authorized) account
+func (ctx *Service) updateProfile(tx txn.UpdateProfileAction, acct *store.Account) tmsp.Result {
+	if err := tx.ValidateProfile(); err != nil {
+		return tmsp.NewError(CodeBadProfile, err.Error())
+	}
+
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	acct.Profile = store.Profile{
+		DisplayName: tx.DisplayName,
+		Bio:         tx.Bio,
+		Website:     tx.Website,
+		AvatarHash:  tx.AvatarHash,
+	}
+	_, err := mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the account key as response
+	key, _ := mom.KeyToBytes(acct.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// Follow adds the posts of another account to the feed of the signer's account
+func (ctx *Service) Follow(tx txn.FollowAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.follow(tx, acct)
+}
+
+// follow runs the action for this (already authorized) account
+func (ctx *Service) follow(tx txn.FollowAction, acct *store.Account) tmsp.Result {
+	if bytes.Equal(tx.Account, acct.ID) {
+		return tmsp.NewError(CodeOwnAccount, "Cannot follow your own account")
+	}
+	if acct.Following >= txn.MaxFollowing {
+		return tmsp.NewError(CodeTooManyFollowing,
+			fmt.Sprintf("Cannot follow more than %d accounts", txn.MaxFollowing))
+	}
+
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	followed, err := store.FindAccountByID(ctx.GetDB(), tx.Account)
+	if err != nil {
+		return storageError(err)
+	}
+	if followed == nil {
+		return tmsp.NewError(CodeNoAccount, "No account with this id")
+	}
+	exists, err := store.FindFollow(ctx.GetDB(), acct.Key(), followed.Key())
+	if err != nil {
+		return storageError(err)
+	}
+	if exists != nil {
+		return tmsp.NewError(CodeAlreadyFollowing, "Already following this account")
+	}
+
+	follow := store.Follow{Follower: acct.Key(), Followed: followed.Key(), Block: ctx.GetHeight()}
+	_, err = mom.Save(ctx.GetDB(), follow)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// if saved, we must update the counts (and sequence) of both accounts
+	return ctx.updateFollowCounts(acct, followed, 1, follow.Key())
+}
+
+// Unfollow removes an account from the feed of the signer's account
+func (ctx *Service) Unfollow(tx txn.UnfollowAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.unfollow(tx, acct)
+}
+
+// unfollow runs the action for this (already authorized) account
+func (ctx *Service) unfollow(tx txn.UnfollowAction, acct *store.Account) tmsp.Result {
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	followed, err := store.FindAccountByID(ctx.GetDB(), tx.Account)
+	if err != nil {
+		return storageError(err)
+	}
+	var exists *store.Follow
+	if followed != nil {
+		exists, err = store.FindFollow(ctx.GetDB(), acct.Key(), followed.Key())
+		if err != nil {
+			return storageError(err)
+		}
+	}
+	if exists == nil {
+		return tmsp.NewError(CodeNotFollowing, "Not following this account")
+	}
+
+	err = store.RemoveFollow(ctx.GetDB(), acct.Key(), followed.Key())
+	if err != nil {
+		return storageError(err)
+	}
+	return ctx.updateFollowCounts(acct, followed, -1, exists.Key())
+}
+
+// updateFollowCounts saves both accounts after a follow (delta 1) or unfollow (delta -1)
+// and returns the key of the follow as response
+func (ctx *Service) updateFollowCounts(acct, followed *store.Account, delta int64, follow mom.Key) tmsp.Result {
+	acct.Following += delta
+	followed.Followers += delta
+	_, err := mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+	_, err = mom.Save(ctx.GetDB(), *followed)
+	if err != nil {
+		return storageError(err)
+	}
+
+	key, _ := mom.KeyToBytes(follow)
+	return tmsp.NewResultOK(key, "")
+}
+
+// RotateKey moves control of the account to a new key, which must countersign.
+// The old key can no longer sign for the account
+func (ctx *Service) RotateKey(tx txn.RotateKeyAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+	if err := tx.ValidateCounterSignature(acct.ID); err != nil {
+		return tmsp.NewError(CodeBadCounterSig, err.Error())
+	}
+
+	// the new key may not control any other account, or have created one
+	newAddr := tx.NewKey.Address()
+	exists, err := store.FindAccount(ctx.GetDB(), tx.NewKey)
+	if err != nil {
+		return storageError(err)
+	}
+	used, err := store.AccountExists(ctx.GetDB(), newAddr)
+	if err != nil {
+		return storageError(err)
+	}
+	if exists != nil || used {
+		return tmsp.NewError(CodeNewKeyUsed,
+			"New key already used by an account")
+	}
+
+	// swap the signer index, the account id stays the same
+	err = store.RemoveSigner(ctx.GetDB(), acct.Signer)
+	if err != nil {
+		return storageError(err)
+	}
+	acct.Signer = newAddr
+	err = store.IndexSigner(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+	_, err = mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the account key as response
+	key, _ := mom.KeyToBytes(acct.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// EndorsePost adds the signature of the signer's account to the post of another account
+func (ctx *Service) EndorsePost(tx txn.EndorsePostAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.endorsePost(tx, acct)
+}
+
+// endorsePost runs the action for this (already authorized) account
+func (ctx *Service) endorsePost(tx txn.EndorsePostAction, acct *store.Account) tmsp.Result {
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	post, res := ctx.loadPost(tx.Post)
+	if res.IsErr() {
+		return res
+	}
+	if res := ctx.checkNotRetracted(*post); res.IsErr() {
+		return res
+	}
+	if author, ok := post.Account.(store.AccountKey); ok && bytes.Equal(author.ID, acct.ID) {
+		return tmsp.NewError(CodeOwnPost, "Cannot endorse your own post")
+	}
+
+	// the endorsement is for the post as it reads now
+	revision, err := store.CountRevisions(ctx.GetDB(), *post)
+	if err != nil {
+		return storageError(err)
+	}
+	end := store.Endorsement{
+		Post:           post.Key(),
+		Account:        acct.Key(),
+		Revision:       revision,
+		Comment:        tx.Comment,
+		PublishedBlock: ctx.GetHeight(),
+	}
+	exists, err := mom.Load(ctx.GetDB(), end.Key())
+	if err != nil {
+		return storageError(err)
+	}
+	if exists != nil {
+		return tmsp.NewError(CodeAlreadyEndorsed,
+			"Post already endorsed by this account")
+	}
+	_, err = mom.Save(ctx.GetDB(), end)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// if saved, we must update the account sequence (the post is left as it was signed)
+	_, err = mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the endorsement key as response
+	ekey, _ := mom.KeyToBytes(end.Key())
+	return tmsp.NewResultOK(ekey, "")
+}
+
+// AttestIdentity records a claim by the signer's account about the identity of another account
+func (ctx *Service) AttestIdentity(tx txn.AttestIdentityAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.attestIdentity(tx, acct)
+}
+
+// attestIdentity runs the action for this (already authorized) account
+func (ctx *Service) attestIdentity(tx txn.AttestIdentityAction, acct *store.Account) tmsp.Result {
+	if err := tx.ValidateClaim(); err != nil {
+		return tmsp.NewError(CodeBadClaim, err.Error())
+	}
+	if tx.ExpiresBlock != 0 && tx.ExpiresBlock <= ctx.GetHeight() {
+		return tmsp.NewError(CodeExpired, "Attestation already expired")
+	}
+	if bytes.Equal(tx.Subject, acct.ID) {
+		return tmsp.NewError(CodeOwnAccount, "Cannot attest your own account")
+	}
+
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	subject, err := store.FindAccountByID(ctx.GetDB(), tx.Subject)
+	if err != nil {
+		return storageError(err)
+	}
+	if subject == nil {
+		return tmsp.NewError(CodeNoAccount, "No subject account with this id")
+	}
+	// a new claim would replace the revoked one, and with it the record of the revocation
+	old, err := store.FindAttestation(ctx.GetDB(), subject.Key(), acct.Key(), tx.Claim)
+	if err != nil {
+		return storageError(err)
+	}
+	if old != nil && old.Revoked {
+		return tmsp.NewError(CodeAlreadyRevoked, "Attestation was revoked, it cannot be attested again")
+	}
+
+	att := store.Attestation{
+		Subject:      subject.Key(),
+		Attester:     acct.Key(),
+		Claim:        tx.Claim,
+		Value:        tx.Value,
+		IssuedBlock:  ctx.GetHeight(),
+		ExpiresBlock: tx.ExpiresBlock,
+	}
+	_, err = mom.Save(ctx.GetDB(), att)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// if saved, we must update account sequence
+	_, err = mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the attestation key as response
+	key, _ := mom.KeyToBytes(att.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// RevokeAttestation revokes a claim the signer's account made about another account
+func (ctx *Service) RevokeAttestation(tx txn.RevokeAttestationAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.revokeAttestation(tx, acct)
+}
+
+// revokeAttestation runs the action for this (already authorized) account
+func (ctx *Service) revokeAttestation(tx txn.RevokeAttestationAction, acct *store.Account) tmsp.Result {
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	// we only look for claims by the signer's account, so no one else can revoke them
+	subject := store.AccountKey{ID: tx.Subject}
+	att, err := store.FindAttestation(ctx.GetDB(), subject, acct.Key(), tx.Claim)
+	if err != nil {
+		return storageError(err)
+	}
+	if att == nil {
+		return tmsp.NewError(CodeNoAttestation, "No such attestation by this account")
+	}
+	if att.Revoked {
+		return tmsp.NewError(CodeAlreadyRevoked, "Attestation already revoked")
+	}
+
+	att.Revoked = true
+	att.RevokedBlock = ctx.GetHeight()
+	_, err = mom.Save(ctx.GetDB(), *att)
+	if err != nil {
+		return storageError(err)
+	}
+	_, err = mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the attestation key as response
+	key, _ := mom.KeyToBytes(att.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// Notarize records the digest of a document for an existing account, if it was never notarized before
+func (ctx *Service) Notarize(tx txn.NotarizeAction, signer crypto.PubKey) tmsp.Result {
+	acct, res := ctx.signerAccount(signer)
+	if res.IsErr() {
+		return res
+	}
+	return ctx.notarize(tx, acct)
+}
+
+// notarize runs the action for this (already authorized) account
+func (ctx *Service) notarize(tx txn.NotarizeAction, acct *store.Account) tmsp.Result {
+	if err := tx.ValidateDigest(); err != nil {
+		return tmsp.NewError(CodeBadDigest, err.Error())
+	}
+
+	// make sure this is not a replay
+	if res := checkSequence(acct, tx.Sequence); res.IsErr() {
+		return res
+	}
+
+	// the first one to notarize a digest wins
+	exists, err := store.FindNotary(ctx.GetDB(), tx.Digest)
+	if err != nil {
+		return storageError(err)
+	}
+	if exists != nil {
+		return tmsp.NewError(CodeAlreadyNotarized,
+			"Digest already notarized")
+	}
+
+	notary := store.Notary{
+		Digest:         tx.Digest,
+		Algorithm:      tx.Algorithm,
+		Title:          tx.Title,
+		Account:        acct.Key(),
+		PublishedBlock: ctx.GetHeight(),
+	}
+	_, err = mom.Save(ctx.GetDB(), notary)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// if saved, we must update account sequence
+	_, err = mom.Save(ctx.GetDB(), *acct)
+	if err != nil {
+		return storageError(err)
+	}
+
+	// return the notary key as response
+	key, _ := mom.KeyToBytes(notary.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// CreateMultisig creates an account that is controlled by a threshold of member keys.
+// The signer must be one of the members
+func (ctx *Service) CreateMultisig(tx txn.CreateMultisigAction, signer crypto.PubKey) tmsp.Result {
+	if signer == nil {
+		return tmsp.NewError(CodeUnsigned, "Must sign transaction")
+	}
+	if err := tx.ValidateName(); err != nil {
+		return tmsp.NewError(CodeInvalidName, err.Error())
+	}
+	if err := tx.ValidateMembers(); err != nil {
+		return tmsp.NewError(CodeBadMembers, err.Error())
+	}
+
+	account, def, err := store.NewMultisig(tx.Name, tx.Threshold, tx.Members)
+	if err != nil {
+		return tmsp.NewError(CodeBadMembers, err.Error())
+	}
+	if !def.IsMember(signer.Address()) {
+		return tmsp.NewError(CodeNotMember, "Signer must be a member")
+	}
+
+	// make sure none with this name or definition already....
+	used, err := store.AccountExists(ctx.GetDB(), account.ID)
+	if err != nil {
+		return storageError(err)
+	}
+	if used {
+		return tmsp.NewError(CodeMultisigExists,
+			"Account exists for these members")
+	}
+	taken, err := store.FindAccountByName(ctx.GetDB(), tx.Name)
+	if err != nil {
+		return storageError(err)
+	}
+	if taken != nil {
+		return tmsp.NewError(CodeNameTaken,
+			"Account name already taken")
+	}
+
+	// all safe, go save it (no signer index, as no single key controls it)
+	_, err = mom.Save(ctx.GetDB(), account)
+	if err == nil {
+		_, err = mom.Save(ctx.GetDB(), def)
+	}
+	if err == nil {
+		err = store.IndexAccount(ctx.GetDB(), account)
+	}
+	if err != nil {
+		return storageError(err)
+	}
+	key, _ := mom.KeyToBytes(account.Key())
+	return tmsp.NewResultOK(key, "")
+}
+
+// ApplyMultisig checks that enough members signed the wrapped action,
+// and runs it for the multisig account
+func (ctx *Service) ApplyMultisig(tx txn.MultisigAction) tmsp.Result {
+	acct, err := store.FindAccountByID(ctx.GetDB(), tx.Account)
+	if err != nil {
+		return storageError(err)
+	}
+	var def *store.Multisig
+	if acct != nil {
+		def, err = store.FindMultisig(ctx.GetDB(), acct.Key())
+	}
+	if err != nil {
+		return storageError(err)
+	}
+	if def == nil {
+		return tmsp.NewError(CodeNoMultisig,
+			"No multisig account with this id")
+	}
+
+	// check the threshold before we even look at the action
+	signers, err := tx.Signers()
+	if err != nil {
+		return tmsp.NewError(CodeBelowThreshold, err.Error())
+	}
+	var count int64
+	for _, addr := range signers {
+		if def.IsMember(addr) {
+			count++
+		}
+	}
+	if count < def.Threshold {
+		return tmsp.NewError(CodeBelowThreshold,
+			fmt.Sprintf("Signed by %d members, %d required", count, def.Threshold))
+	}
+
+	action, err := tx.GetAction()
+	if err != nil {
+		return tmsp.NewError(CodeInvalidTx, err.Error())
+	}
+	switch inner := action.(type) {
+	case txn.AddPostAction:
+		return ctx.appendPost(inner, acct)
+	case txn.EditPostAction:
+		return ctx.editPost(inner, acct)
+	case txn.RetractPostAction:
+		return ctx.retractPost(inner, acct)
+	case txn.NotarizeAction:
+		return ctx.notarize(inner, acct)
+	case txn.EndorsePostAction:
+		return ctx.endorsePost(inner, acct)
+	case txn.AttestIdentityAction:
+		return ctx.attestIdentity(inner, acct)
+	case txn.RevokeAttestationAction:
+		return ctx.revokeAttestation(inner, acct)
+	case txn.UpdateProfileAction:
+		return ctx.updateProfile(inner, acct)
+	case txn.FollowAction:
+		return ctx.follow(inner, acct)
+	case txn.UnfollowAction:
+		return ctx.unfollow(inner, acct)
+	}
+	return tmsp.NewError(CodeMultisigForbidden, "Action not supported for multisig accounts")
+}
+
+// loadPost loads the post with this (serialized) key, which may belong to any account
+func (ctx *Service) loadPost(data []byte) (*store.Post, tmsp.Result) {
+	key, err := mom.KeyFromBytes(data)
+	if err != nil {
+		return nil, tmsp.NewError(CodeBadPostKey, err.Error())
+	}
+	postKey, ok := key.(store.PostKey)
+	if !ok {
+		return nil, tmsp.NewError(CodeBadPostKey, "Not a post key")
+	}
+	model, err := mom.Load(ctx.GetDB(), postKey)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if model == nil {
+		return nil, tmsp.NewError(CodeNoPost, "No post with this key")
+	}
+	post := model.(store.Post)
+	return &post, tmsp.NewResultOK(nil, "")
+}
+
+// checkNotRetracted returns an error if the post was retracted
+func (ctx *Service) checkNotRetracted(post store.Post) tmsp.Result {
+	tomb, err := store.FindRetraction(ctx.GetDB(), post.Key())
+	if err != nil {
+		return storageError(err)
+	}
+	if tomb != nil {
+		return tmsp.NewError(CodePostRetracted, "Post was retracted")
+	}
+	return tmsp.NewResultOK(nil, "")
+}
+
+// signerAccount finds the account controlled by the signer of the tx
+func (ctx *Service) signerAccount(signer crypto.PubKey) (*store.Account, tmsp.Result) {
+	if signer == nil {
+		return nil, tmsp.NewError(CodeUnsigned, "Must sign transaction")
+	}
+	acct, err := store.FindAccount(ctx.GetDB(), signer)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if acct == nil {
+		return nil, tmsp.NewError(CodeNoSignerAccount,
+			"No account exists for this public key")
+	}
+	return acct, tmsp.NewResultOK(nil, "")
+}
+
+// checkSequence makes sure the tx is the next one signed by this account, and updates
+// the account sequence (which must be saved by the caller if the tx succeeds)
+func checkSequence(acct *store.Account, sequence int64) tmsp.Result {
+	expected := acct.Sequence + 1
+	if sequence != expected {
+		return tmsp.NewError(CodeBadSequence,
+			fmt.Sprintf("Invalid sequence %d, expected %d", sequence, expected))
+	}
+	acct.Sequence = sequence
+	return tmsp.NewResultOK(nil, "")
+}
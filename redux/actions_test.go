@@ -1,10 +1,13 @@
 package redux
 
 import (
+	"crypto/sha256"
+	"strings"
 	"testing"
 
 	"github.com/ethanfrey/signedpost/store"
 	"github.com/ethanfrey/signedpost/txn"
+	"github.com/ethanfrey/tenderize/mom"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tendermint/go-crypto"
@@ -31,7 +34,8 @@ func TestCreateUser(t *testing.T) {
 	// success for self-creation
 	r = srv.CreateAccount(tx, alice.PubKey())
 	assert.False(r.IsErr(), r.Error())
-	assert.Equal(1, tree.Size())
+	// (account plus two name index entries and the signer index)
+	assert.Equal(4, tree.Size())
 
 	// let's check this account by key
 	data, err := store.FindAccount(tree, alice.PubKey())
@@ -41,20 +45,26 @@ func TestCreateUser(t *testing.T) {
 	}
 
 	// let's check this account by name
-	matches, err := store.ListAccounts(tree, store.AccountMatchesName("Alice"))
+	data, err = store.FindAccountByName(tree, "Alice")
 	assert.Nil(err)
-	if assert.Equal(1, len(matches)) {
-		assert.Equal(matches[0].Name, "Alice")
+	if assert.NotNil(data) {
+		assert.Equal(data.Name, "Alice")
 	}
 
 	// error by second name
 	tx2 := txn.CreateAccountAction{Name: "Bob"}
 	r = srv.CreateAccount(tx, alice.PubKey())
-	assert.True(r.IsErr(), "%+v", r.Code)
+	assert.Equal(CodeKeyHasAccount, r.Code)
 
 	// cannot claim the same name (taken)
 	r = srv.CreateAccount(tx, bob.PubKey())
-	assert.True(r.IsErr(), "%+v", r.Code)
+	assert.Equal(CodeNameTaken, r.Code)
+	// names must be valid
+	carl := crypto.GenPrivKeyEd25519().PubKey()
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: ""}, carl)
+	assert.Equal(CodeInvalidName, r.Code)
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: strings.Repeat("x", txn.MaxNameLength+1)}, carl)
+	assert.Equal(CodeInvalidName, r.Code)
 	// but he can claim his own name
 	r = srv.CreateAccount(tx2, bob.PubKey())
 	assert.False(r.IsErr(), r.Error())
@@ -75,8 +85,9 @@ func TestAppendPost(t *testing.T) {
 	}
 
 	tx := txn.AddPostAction{
-		Title:   "My First Post",
-		Content: "some data",
+		Title:    "My First Post",
+		Content:  "some data",
+		Sequence: 1,
 	}
 
 	// anon is prevented
@@ -93,13 +104,13 @@ func TestAppendPost(t *testing.T) {
 	utx := txn.CreateAccountAction{Name: "Alice"}
 	r = srv.CreateAccount(utx, pub)
 	assert.False(r.IsErr(), r.Error())
-	assert.Equal(1, tree.Size())
+	assert.Equal(4, tree.Size())
 	// acctKey := r.Data
 
 	// now, let's add a post...
 	r = srv.AppendPost(tx, pub)
 	assert.False(r.IsErr(), "%+v", r.Error())
-	assert.Equal(2, tree.Size())
+	assert.Equal(5, tree.Size())
 	// postKey := r.Data
 
 	// let's check the post
@@ -125,12 +136,23 @@ func TestAppendPost(t *testing.T) {
 
 	// add a second post and make sure we query both
 	tx2 := txn.AddPostAction{
-		Title:   "Quick Update",
-		Content: "We can add multiple posts",
+		Title:    "Quick Update",
+		Content:  "We can add multiple posts",
+		Sequence: 2,
 	}
+	// cannot replay the first post
+	r = srv.AppendPost(tx, pub)
+	assert.Equal(CodeBadSequence, r.Code)
+	// nor skip ahead
+	tx2.Sequence = 3
+	r = srv.AppendPost(tx2, pub)
+	assert.Equal(CodeBadSequence, r.Code)
+	assert.EqualValues(5, tree.Size())
+
+	tx2.Sequence = 2
 	r = srv.AppendPost(tx2, pub)
 	assert.False(r.IsErr(), "%+v", r.Error())
-	assert.EqualValues(3, tree.Size())
+	assert.EqualValues(6, tree.Size())
 
 	// get the account and check it was updated
 	aa, err = store.FindAccount(tree, pub)
@@ -138,6 +160,7 @@ func TestAppendPost(t *testing.T) {
 	if assert.NotNil(aa) {
 		assert.Equal("Alice", aa.Name)
 		assert.EqualValues(2, aa.EntryCount)
+		assert.EqualValues(2, aa.Sequence)
 	}
 
 	// let's check the post
@@ -149,3 +172,671 @@ func TestAppendPost(t *testing.T) {
 	assert.Equal(tx2.Title, posts[1].Title)
 	assert.EqualValues(2, posts[1].Number)
 }
+
+func TestNotarize(t *testing.T) {
+	assert := assert.New(t)
+	alice := crypto.GenPrivKeyEd25519()
+	pub := alice.PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := &Service{
+		store:       tree,
+		blockHeight: 5,
+	}
+	digest := sha256.Sum256([]byte("Top secret contract"))
+	tx := txn.NotarizeAction{
+		Algorithm: txn.HashSHA256,
+		Digest:    digest[:],
+		Title:     "Contract",
+		Sequence:  1,
+	}
+
+	// anon and unregistered are prevented
+	r := srv.Notarize(tx, nil)
+	assert.True(r.IsErr(), "%+v", r.Code)
+	r = srv.Notarize(tx, pub)
+	assert.True(r.IsErr(), "%+v", r.Code)
+
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, pub)
+	assert.False(r.IsErr(), r.Error())
+
+	// bad digests are prevented
+	bad := txn.NotarizeAction{Algorithm: txn.HashSHA512, Digest: digest[:]}
+	r = srv.Notarize(bad, pub)
+	assert.True(r.IsErr(), "%+v", r.Code)
+	bad = txn.NotarizeAction{Algorithm: "md5", Digest: digest[:16]}
+	r = srv.Notarize(bad, pub)
+	assert.True(r.IsErr(), "%+v", r.Code)
+
+	// success the first time
+	r = srv.Notarize(tx, pub)
+	assert.False(r.IsErr(), r.Error())
+	n, err := store.FindNotary(tree, digest[:])
+	assert.Nil(err)
+	if assert.NotNil(n) {
+		assert.Equal("Contract", n.Title)
+		assert.EqualValues(5, n.PublishedBlock)
+	}
+
+	// but never again
+	tx.Sequence = 2
+	r = srv.Notarize(tx, pub)
+	assert.True(r.IsErr(), "%+v", r.Code)
+}
+
+func TestEditPost(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519().PubKey()
+	bob := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Bob"}, bob)
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Helo", Content: "Wrld", Sequence: 1}, alice)
+	require.False(r.IsErr(), r.Error())
+	_, original, _ := tree.Get(r.Data)
+	postKey := r.Data
+
+	edit := txn.EditPostAction{Number: 1, Title: "Hello", Content: "World", Sequence: 2}
+
+	// anon is prevented
+	r = srv.EditPost(edit, nil)
+	assert.Equal(CodeUnsigned, r.Code)
+	// bob cannot edit alice's post (he has no post 1)
+	bobEdit := edit
+	bobEdit.Sequence = 1
+	r = srv.EditPost(bobEdit, bob)
+	assert.Equal(CodeNoPost, r.Code)
+	// nor a post that doesn't exist
+	missing := edit
+	missing.Number = 2
+	r = srv.EditPost(missing, alice)
+	assert.Equal(CodeNoPost, r.Code)
+
+	// two edits work
+	srv.SetBlock(7)
+	r = srv.EditPost(edit, alice)
+	require.False(r.IsErr(), r.Error())
+	// no replay
+	r = srv.EditPost(edit, alice)
+	assert.Equal(CodeBadSequence, r.Code)
+	edit.Content, edit.Sequence = "World!", 3
+	r = srv.EditPost(edit, alice)
+	require.False(r.IsErr(), r.Error())
+
+	// the original is untouched, so its proofs stay valid
+	_, edited, _ := tree.Get(postKey)
+	assert.Equal(original, edited)
+	acct, err := store.FindAccount(tree, alice)
+	require.Nil(err)
+	posts, err := store.ListPosts(tree, store.PostsForAccount(*acct, 1), nil)
+	require.Nil(err)
+	require.Equal(1, len(posts))
+	assert.Equal("Helo", posts[0].Title)
+	assert.Equal("Wrld", posts[0].Content)
+	count, err := store.CountRevisions(tree, posts[0])
+	require.Nil(err)
+	assert.EqualValues(2, count)
+
+	// and we have the full history
+	revs, err := store.ListRevisions(tree, posts[0].Key())
+	require.Nil(err)
+	require.Equal(2, len(revs))
+	assert.Equal("World", revs[0].Content)
+	assert.EqualValues(7, revs[0].PublishedBlock)
+	assert.Equal("World!", revs[1].Content)
+	latest, err := store.LatestRevision(tree, posts[0])
+	require.Nil(err)
+	if assert.NotNil(latest) {
+		assert.Equal(revs[1], *latest)
+	}
+}
+
+func TestRetractPost(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519().PubKey()
+	bob := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Bob"}, bob)
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Oops", Sequence: 1}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Fine", Sequence: 2}, alice)
+	require.False(r.IsErr(), r.Error())
+
+	tx := txn.RetractPostAction{Number: 1, Reason: "Published by mistake", Sequence: 3}
+
+	// anon is prevented
+	r = srv.RetractPost(tx, nil)
+	assert.Equal(CodeUnsigned, r.Code)
+	// bob cannot retract alice's post
+	bobTx := tx
+	bobTx.Sequence = 1
+	r = srv.RetractPost(bobTx, bob)
+	assert.Equal(CodeNoPost, r.Code)
+
+	// alice can, but only once
+	srv.SetBlock(8)
+	r = srv.RetractPost(tx, alice)
+	require.False(r.IsErr(), r.Error())
+	tx.Sequence = 4
+	r = srv.RetractPost(tx, alice)
+	assert.Equal(CodeAlreadyRetracted, r.Code)
+	// and it can no longer be edited
+	r = srv.EditPost(txn.EditPostAction{Number: 1, Title: "Fixed", Sequence: 4}, alice)
+	assert.Equal(CodePostRetracted, r.Code)
+
+	// the post is kept with the original content, and the tombstone next to it
+	acct, err := store.FindAccount(tree, alice)
+	require.Nil(err)
+	posts, err := store.ListPosts(tree, store.PostsForAccount(*acct, 0), nil)
+	require.Nil(err)
+	require.Equal(2, len(posts))
+	assert.Equal("Oops", posts[0].Title)
+	details, err := store.LoadPostDetails(tree, posts[0])
+	require.Nil(err)
+	if assert.NotNil(details.Retraction) {
+		assert.Equal("Published by mistake", details.Retraction.Reason)
+		assert.EqualValues(8, details.Retraction.PublishedBlock)
+	}
+	details, err = store.LoadPostDetails(tree, posts[1])
+	require.Nil(err)
+	assert.Nil(details.Retraction)
+
+	// and we can hide it from listings
+	visible, _, err := store.PagePosts(tree, store.PostsForAccount(*acct, 0), store.PostNotRetracted(tree), store.Page{})
+	require.Nil(err)
+	if assert.Equal(1, len(visible)) {
+		assert.Equal("Fine", visible[0].Title)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519()
+	newKey := crypto.GenPrivKeyEd25519()
+	bob := crypto.GenPrivKeyEd25519()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, alice.PubKey())
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Bob"}, bob.PubKey())
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Before", Sequence: 1}, alice.PubKey())
+	require.False(r.IsErr(), r.Error())
+	orig, err := store.FindAccount(tree, alice.PubKey())
+	require.Nil(err)
+	require.NotNil(orig)
+
+	tx := txn.RotateKeyAction{Sequence: 2}
+	require.Nil(tx.CounterSign(orig.ID, newKey))
+
+	// anon is prevented
+	r = srv.RotateKey(tx, nil)
+	assert.Equal(CodeUnsigned, r.Code)
+	// the countersignature is only valid for alice's account
+	bobTx := tx
+	bobTx.Sequence = 1
+	r = srv.RotateKey(bobTx, bob.PubKey())
+	assert.Equal(CodeBadCounterSig, r.Code)
+	// and must be by the new key
+	forged := tx
+	forged.NewKey = bob.PubKey()
+	r = srv.RotateKey(forged, alice.PubKey())
+	assert.Equal(CodeBadCounterSig, r.Code)
+	// cannot move to a key that controls another account
+	taken := txn.RotateKeyAction{Sequence: 2}
+	require.Nil(taken.CounterSign(orig.ID, bob))
+	r = srv.RotateKey(taken, alice.PubKey())
+	assert.Equal(CodeNewKeyUsed, r.Code)
+
+	// success
+	r = srv.RotateKey(tx, alice.PubKey())
+	require.False(r.IsErr(), r.Error())
+
+	// the old key has no account, the new key has the same one
+	acct, err := store.FindAccount(tree, alice.PubKey())
+	assert.Nil(err)
+	assert.Nil(acct)
+	acct, err = store.FindAccount(tree, newKey.PubKey())
+	require.Nil(err)
+	if assert.NotNil(acct) {
+		assert.Equal(orig.ID, acct.ID)
+		assert.Equal(newKey.PubKey().Address(), acct.Signer)
+		assert.EqualValues(1, acct.EntryCount)
+		assert.EqualValues(2, acct.Sequence)
+	}
+
+	// the old key can no longer post, nor reclaim the account id
+	r = srv.AppendPost(txn.AddPostAction{Title: "Hijack", Sequence: 3}, alice.PubKey())
+	assert.Equal(CodeNoSignerAccount, r.Code)
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Alice2"}, alice.PubKey())
+	assert.Equal(CodeKeyWasUsed, r.Code)
+
+	// but the new key can, and the posts stay in the same account
+	r = srv.AppendPost(txn.AddPostAction{Title: "After", Sequence: 3}, newKey.PubKey())
+	require.False(r.IsErr(), r.Error())
+	posts, err := store.ListPosts(tree, store.PostsForAccount(*orig, 0), nil)
+	require.Nil(err)
+	if assert.Equal(2, len(posts)) {
+		assert.Equal("Before", posts[0].Title)
+		assert.Equal("After", posts[1].Title)
+	}
+}
+
+func TestMultisig(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	officers := []crypto.PrivKey{
+		crypto.GenPrivKeyEd25519(),
+		crypto.GenPrivKeyEd25519(),
+		crypto.GenPrivKeyEd25519(),
+	}
+	members := make([][]byte, len(officers))
+	for i, o := range officers {
+		members[i] = o.PubKey().Address()
+	}
+	outsider := crypto.GenPrivKeyEd25519()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+	srv := New(tree, 5)
+
+	// only a member can create it, and it must be valid
+	create := txn.CreateMultisigAction{Name: "Legal", Threshold: 2, Members: members}
+	r := srv.CreateMultisig(create, outsider.PubKey())
+	assert.Equal(CodeNotMember, r.Code)
+	bad := create
+	bad.Threshold = 4
+	r = srv.CreateMultisig(bad, officers[0].PubKey())
+	assert.Equal(CodeBadMembers, r.Code)
+	r = srv.CreateMultisig(create, officers[0].PubKey())
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateMultisig(create, officers[1].PubKey())
+	assert.Equal(CodeMultisigExists, r.Code)
+
+	acct, err := store.FindAccountByName(tree, "Legal")
+	require.Nil(err)
+	require.NotNil(acct)
+	// no single key controls it
+	r = srv.AppendPost(txn.AddPostAction{Title: "Solo", Sequence: 1}, officers[0].PubKey())
+	assert.Equal(CodeNoSignerAccount, r.Code)
+
+	post := txn.AddPostAction{Title: "Statement", Content: "Approved by legal", Sequence: 1}
+	tx, err := txn.NewMultisigAction(acct.ID, post)
+	require.Nil(err)
+
+	// one member is not enough, nor one member twice, nor an outsider
+	require.Nil(tx.AddSignature(officers[0]))
+	r = srv.ApplyMultisig(tx)
+	assert.Equal(CodeBelowThreshold, r.Code)
+	require.Nil(tx.AddSignature(officers[0]))
+	require.Nil(tx.AddSignature(outsider))
+	r = srv.ApplyMultisig(tx)
+	assert.Equal(CodeBelowThreshold, r.Code)
+	// a forged signature fails it all
+	forged := tx
+	forged.Signatures = append([]txn.MemberSignature{}, tx.Signatures...)
+	forged.Signatures[0].PubKey = officers[2].PubKey()
+	r = srv.ApplyMultisig(forged)
+	assert.Equal(CodeBelowThreshold, r.Code)
+
+	// two of three works, but only once
+	require.Nil(tx.AddSignature(officers[2]))
+	r = srv.ApplyMultisig(tx)
+	require.False(r.IsErr(), r.Error())
+	r = srv.ApplyMultisig(tx)
+	assert.Equal(CodeBadSequence, r.Code)
+
+	posts, err := store.ListPosts(tree, store.PostsForAccount(*acct, 0), nil)
+	require.Nil(err)
+	if assert.Equal(1, len(posts)) {
+		assert.Equal("Statement", posts[0].Title)
+	}
+
+	// the signatures are only valid for this account
+	other := txn.CreateMultisigAction{Name: "Other", Threshold: 1, Members: members[:2]}
+	r = srv.CreateMultisig(other, officers[0].PubKey())
+	require.False(r.IsErr(), r.Error())
+	otherAcct, err := store.FindAccountByName(tree, "Other")
+	require.Nil(err)
+	moved := tx
+	moved.Account = otherAcct.ID
+	r = srv.ApplyMultisig(moved)
+	assert.Equal(CodeBelowThreshold, r.Code)
+
+	// and it cannot wrap account changes
+	rotate, err := txn.NewMultisigAction(acct.ID, txn.RotateKeyAction{Sequence: 2})
+	require.Nil(err)
+	require.Nil(rotate.AddSignature(officers[0]))
+	require.Nil(rotate.AddSignature(officers[1]))
+	r = srv.ApplyMultisig(rotate)
+	assert.Equal(CodeMultisigForbidden, r.Code)
+}
+
+func TestEndorsePost(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519().PubKey()
+	bob := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Bob"}, bob)
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Agreement", Content: "We agree", Sequence: 1}, alice)
+	require.False(r.IsErr(), r.Error())
+	postKey := r.Data
+
+	tx := txn.EndorsePostAction{Post: postKey, Comment: "Agreed", Sequence: 1}
+
+	// anon is prevented, as is a bad key and your own post
+	r = srv.EndorsePost(tx, nil)
+	assert.Equal(CodeUnsigned, r.Code)
+	bad := tx
+	bad.Post = []byte{1, 2, 3}
+	r = srv.EndorsePost(bad, bob)
+	assert.Equal(CodeBadPostKey, r.Code)
+	own := tx
+	own.Sequence = 2
+	r = srv.EndorsePost(own, alice)
+	assert.Equal(CodeOwnPost, r.Code)
+
+	// bob can endorse it once
+	_, original, _ := tree.Get(postKey)
+	srv.SetBlock(6)
+	r = srv.EndorsePost(tx, bob)
+	require.False(r.IsErr(), r.Error())
+	tx.Sequence = 2
+	r = srv.EndorsePost(tx, bob)
+	assert.Equal(CodeAlreadyEndorsed, r.Code)
+
+	acct, err := store.FindAccount(tree, alice)
+	require.Nil(err)
+	posts, err := store.ListPosts(tree, store.PostsForAccount(*acct, 1), nil)
+	require.Nil(err)
+	require.Equal(1, len(posts))
+	// the post itself is not changed, so its proofs stay valid
+	_, endorsed, _ := tree.Get(postKey)
+	assert.Equal(original, endorsed)
+	details, err := store.LoadPostDetails(tree, posts[0])
+	require.Nil(err)
+	if assert.Equal(1, len(details.Endorsements)) {
+		end := details.Endorsements[0]
+		assert.Equal("Agreed", end.Comment)
+		assert.EqualValues(6, end.PublishedBlock)
+		assert.EqualValues(0, end.Revision)
+		bobAcct, err := store.FindAccount(tree, bob)
+		require.Nil(err)
+		assert.Equal(bobAcct.Key(), end.Account)
+	}
+}
+
+func TestAttestIdentity(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519().PubKey()
+	notary := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Notary"}, notary)
+	require.False(r.IsErr(), r.Error())
+
+	tx := txn.AttestIdentityAction{Subject: alice.Address(), Claim: "email", Value: "alice@example.com", ExpiresBlock: 10, Sequence: 1}
+
+	// anon is prevented, as is an unknown subject, an expired claim and self-attestation
+	r = srv.AttestIdentity(tx, nil)
+	assert.Equal(CodeUnsigned, r.Code)
+	bad := tx
+	bad.Subject = []byte("12345678901234567890")
+	r = srv.AttestIdentity(bad, notary)
+	assert.Equal(CodeNoAccount, r.Code)
+	bad = tx
+	bad.ExpiresBlock = 5
+	r = srv.AttestIdentity(bad, notary)
+	assert.Equal(CodeExpired, r.Code)
+	bad = tx
+	bad.Claim = ""
+	r = srv.AttestIdentity(bad, notary)
+	assert.Equal(CodeBadClaim, r.Code)
+	r = srv.AttestIdentity(tx, alice)
+	assert.Equal(CodeOwnAccount, r.Code)
+
+	// the notary can vouch for alice
+	r = srv.AttestIdentity(tx, notary)
+	require.False(r.IsErr(), r.Error())
+	subject := store.AccountKey{ID: alice.Address()}
+	atts, err := store.ListAttestations(tree, subject)
+	require.Nil(err)
+	if assert.Equal(1, len(atts)) {
+		att := atts[0]
+		assert.Equal("alice@example.com", att.Value)
+		assert.EqualValues(5, att.IssuedBlock)
+		assert.True(att.IsValid(9))
+		assert.False(att.IsValid(10))
+	}
+
+	// only the attester can revoke it, and only once
+	rev := txn.RevokeAttestationAction{Subject: alice.Address(), Claim: "email", Sequence: 1}
+	r = srv.RevokeAttestation(rev, alice)
+	assert.Equal(CodeNoAttestation, r.Code)
+	srv.SetBlock(7)
+	rev.Sequence = 2
+	r = srv.RevokeAttestation(rev, notary)
+	require.False(r.IsErr(), r.Error())
+	rev.Sequence = 3
+	r = srv.RevokeAttestation(rev, notary)
+	assert.Equal(CodeAlreadyRevoked, r.Code)
+
+	att, err := store.FindAttestation(tree, subject, store.AccountKey{ID: notary.Address()}, "email")
+	require.Nil(err)
+	require.NotNil(att)
+	assert.True(att.Revoked)
+	assert.EqualValues(7, att.RevokedBlock)
+	assert.False(att.IsValid(8))
+
+	// and the revocation cannot be overwritten by attesting the claim again
+	tx.Sequence, tx.ExpiresBlock = 3, 0
+	r = srv.AttestIdentity(tx, notary)
+	assert.Equal(CodeAlreadyRevoked, r.Code)
+	att, err = store.FindAttestation(tree, subject, store.AccountKey{ID: notary.Address()}, "email")
+	require.Nil(err)
+	require.NotNil(att)
+	assert.True(att.Revoked)
+}
+
+func TestUpdateProfile(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "alice"}, alice)
+	require.False(r.IsErr(), r.Error())
+
+	tx := txn.UpdateProfileAction{DisplayName: "Alice W.", Website: "https://alice.example.com", Sequence: 1}
+
+	// anon is prevented, as is an invalid field
+	r = srv.UpdateProfile(tx, nil)
+	assert.Equal(CodeUnsigned, r.Code)
+	bad := tx
+	bad.Website = "not a url"
+	r = srv.UpdateProfile(bad, alice)
+	assert.Equal(CodeBadProfile, r.Code)
+
+	// set the profile, and then replace it
+	r = srv.UpdateProfile(tx, alice)
+	require.False(r.IsErr(), r.Error())
+	acct, err := store.FindAccount(tree, alice)
+	require.Nil(err)
+	assert.Equal("alice", acct.Name)
+	assert.Equal("Alice W.", acct.Profile.DisplayName)
+	assert.Equal("https://alice.example.com", acct.Profile.Website)
+
+	r = srv.UpdateProfile(txn.UpdateProfileAction{Bio: "Just Alice", Sequence: 2}, alice)
+	require.False(r.IsErr(), r.Error())
+	acct, err = store.FindAccount(tree, alice)
+	require.Nil(err)
+	assert.Equal("", acct.Profile.DisplayName)
+	assert.Equal("Just Alice", acct.Profile.Bio)
+	assert.EqualValues(2, acct.Sequence)
+
+	// the name index still finds the account
+	acct, err = store.FindAccountByName(tree, "alice")
+	require.Nil(err)
+	require.NotNil(acct)
+	assert.Equal("Just Alice", acct.Profile.Bio)
+}
+
+func TestReplies(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519().PubKey()
+	bob := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Bob"}, bob)
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Question", Sequence: 1}, alice)
+	require.False(r.IsErr(), r.Error())
+	parent := r.Data
+	_, original, _ := tree.Get(parent)
+
+	// the parent must be an existing post
+	r = srv.AppendPost(txn.AddPostAction{Title: "Huh?", Parent: []byte{1, 2, 3}, Sequence: 1}, bob)
+	assert.Equal(CodeBadPostKey, r.Code)
+	acct, err := store.FindAccount(tree, alice)
+	require.Nil(err)
+	missing, _ := mom.KeyToBytes(store.PostsForAccount(*acct, 7))
+	r = srv.AppendPost(txn.AddPostAction{Title: "Huh?", Parent: missing, Sequence: 1}, bob)
+	assert.Equal(CodeNoPost, r.Code)
+
+	// bob replies, and alice replies to that
+	r = srv.AppendPost(txn.AddPostAction{Title: "Answer", Parent: parent, Sequence: 1}, bob)
+	require.False(r.IsErr(), r.Error())
+	answer := r.Data
+	r = srv.AppendPost(txn.AddPostAction{Title: "Thanks", Parent: answer, Sequence: 2}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Another answer", Parent: parent, Sequence: 2}, bob)
+	require.False(r.IsErr(), r.Error())
+
+	first, err := store.ListPosts(tree, store.PostsForAccount(*acct, 1), nil)
+	require.Nil(err)
+	require.Equal(1, len(first))
+	assert.Nil(first[0].Parent)
+	count, err := store.CountReplies(tree, first[0].Key())
+	require.Nil(err)
+	assert.EqualValues(2, count)
+	// the parent itself is not changed, so its proofs stay valid
+	_, replied, _ := tree.Get(parent)
+	assert.Equal(original, replied)
+
+	replies, next, err := store.PageReplies(tree, first[0].Key(), nil, store.Page{})
+	require.Nil(err)
+	assert.Nil(next)
+	if assert.Equal(2, len(replies)) {
+		assert.Equal("Answer", replies[0].Title)
+		assert.Equal("Another answer", replies[1].Title)
+		assert.Equal(first[0].Key(), replies[0].Parent)
+		count, err = store.CountReplies(tree, replies[0].Key())
+		require.Nil(err)
+		assert.EqualValues(1, count)
+	}
+
+	// no replies to a retracted post
+	r = srv.RetractPost(txn.RetractPostAction{Number: 1, Sequence: 3}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.AppendPost(txn.AddPostAction{Title: "Too late", Parent: parent, Sequence: 3}, bob)
+	assert.Equal(CodePostRetracted, r.Code)
+}
+
+func TestFollow(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	alice := crypto.GenPrivKeyEd25519().PubKey()
+	bob := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	srv := New(tree, 5)
+	r := srv.CreateAccount(txn.CreateAccountAction{Name: "Alice"}, alice)
+	require.False(r.IsErr(), r.Error())
+	r = srv.CreateAccount(txn.CreateAccountAction{Name: "Bob"}, bob)
+	require.False(r.IsErr(), r.Error())
+
+	tx := txn.FollowAction{Account: bob.Address(), Sequence: 1}
+
+	// anon is prevented, as is following yourself or an unknown account
+	r = srv.Follow(tx, nil)
+	assert.Equal(CodeUnsigned, r.Code)
+	r = srv.Follow(tx, bob)
+	assert.Equal(CodeOwnAccount, r.Code)
+	r = srv.Follow(txn.FollowAction{Account: []byte("12345678901234567890"), Sequence: 1}, alice)
+	assert.Equal(CodeNoAccount, r.Code)
+
+	// alice can follow bob once
+	r = srv.Follow(tx, alice)
+	require.False(r.IsErr(), r.Error())
+	tx.Sequence = 2
+	r = srv.Follow(tx, alice)
+	assert.Equal(CodeAlreadyFollowing, r.Code)
+
+	aliceAcct, err := store.FindAccount(tree, alice)
+	require.Nil(err)
+	bobAcct, err := store.FindAccount(tree, bob)
+	require.Nil(err)
+	assert.EqualValues(1, aliceAcct.Following)
+	assert.EqualValues(0, aliceAcct.Followers)
+	assert.EqualValues(1, bobAcct.Followers)
+	assert.EqualValues(0, bobAcct.Following)
+
+	// and unfollow him once
+	un := txn.UnfollowAction{Account: bob.Address(), Sequence: 2}
+	r = srv.Unfollow(un, alice)
+	require.False(r.IsErr(), r.Error())
+	un.Sequence = 3
+	r = srv.Unfollow(un, alice)
+	assert.Equal(CodeNotFollowing, r.Code)
+
+	aliceAcct, err = store.FindAccount(tree, alice)
+	require.Nil(err)
+	bobAcct, err = store.FindAccount(tree, bob)
+	require.Nil(err)
+	assert.EqualValues(0, aliceAcct.Following)
+	assert.EqualValues(0, bobAcct.Followers)
+	assert.EqualValues(2, aliceAcct.Sequence)
+	follows, err := store.ListFollowing(tree, aliceAcct.Key())
+	require.Nil(err)
+	assert.Empty(follows)
+}
+
+func TestErrorCodes(t *testing.T) {
+	assert := assert.New(t)
+
+	// every code is documented once, with a unique name and a known kind
+	codes, names := map[int32]bool{}, map[string]bool{}
+	kinds := map[string]bool{KindInvalid: true, KindUnauthorized: true, KindNotFound: true, KindConflict: true, KindInternal: true}
+	for _, ec := range ErrorCodes() {
+		assert.False(codes[int32(ec.Code)], "%d", ec.Code)
+		assert.False(names[ec.Name], ec.Name)
+		assert.True(kinds[ec.Kind], ec.Kind)
+		assert.NotEmpty(ec.Description)
+		assert.True(ec.Code >= 1000, "%d", ec.Code)
+		codes[int32(ec.Code)], names[ec.Name] = true, true
+	}
+
+	ec, ok := LookupErrorCode(CodeNameTaken)
+	assert.True(ok)
+	assert.Equal("name_taken", ec.Name)
+	assert.Equal(KindConflict, ec.Kind)
+	_, ok = LookupErrorCode(CodeBadSequence - 1000)
+	assert.False(ok)
+}
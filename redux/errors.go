@@ -0,0 +1,127 @@
+package redux
+
+import (
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// Error codes returned by CheckTx and AppendTx, one for every reason we reject a tx.
+// They are part of the consensus (stored in every block), so never renumber them.
+// They start at 1000 to stay clear of the codes defined by tmsp
+const (
+	CodeInvalidTx       tmsp.CodeType = 1000
+	CodeUnknownAction   tmsp.CodeType = 1001
+	CodeStorage         tmsp.CodeType = 1002
+	CodeUnsigned        tmsp.CodeType = 1003
+	CodeNoSignerAccount tmsp.CodeType = 1004
+	CodeBadSequence     tmsp.CodeType = 1005
+
+	CodeInvalidName   tmsp.CodeType = 1010
+	CodeKeyHasAccount tmsp.CodeType = 1011
+	CodeKeyWasUsed    tmsp.CodeType = 1012
+	CodeNameTaken     tmsp.CodeType = 1013
+	CodeNoAccount     tmsp.CodeType = 1014
+	CodeBadProfile    tmsp.CodeType = 1015
+
+	CodeBadPostKey        tmsp.CodeType = 1020
+	CodeNoPost            tmsp.CodeType = 1021
+	CodePostRetracted     tmsp.CodeType = 1022
+	CodeAlreadyRetracted  tmsp.CodeType = 1023
+	CodeOwnPost           tmsp.CodeType = 1024
+	CodeAlreadyEndorsed   tmsp.CodeType = 1025
+	CodeBadDigest         tmsp.CodeType = 1026
+	CodeAlreadyNotarized  tmsp.CodeType = 1027
+	CodeOwnAccount        tmsp.CodeType = 1028
+	CodeTooManyFollowing  tmsp.CodeType = 1029
+	CodeAlreadyFollowing  tmsp.CodeType = 1030
+	CodeNotFollowing      tmsp.CodeType = 1031
+	CodeBadClaim          tmsp.CodeType = 1032
+	CodeExpired           tmsp.CodeType = 1033
+	CodeNoAttestation     tmsp.CodeType = 1034
+	CodeAlreadyRevoked    tmsp.CodeType = 1035
+	CodeBadCounterSig     tmsp.CodeType = 1036
+	CodeNewKeyUsed        tmsp.CodeType = 1037
+	CodeBadMembers        tmsp.CodeType = 1038
+	CodeNotMember         tmsp.CodeType = 1039
+	CodeMultisigExists    tmsp.CodeType = 1040
+	CodeNoMultisig        tmsp.CodeType = 1041
+	CodeBelowThreshold    tmsp.CodeType = 1042
+	CodeMultisigForbidden tmsp.CodeType = 1043
+)
+
+// The kind of an error code tells the client who has to fix what
+const (
+	KindInvalid      = "invalid"      // the tx itself is malformed, never resend it
+	KindUnauthorized = "unauthorized" // the signatures do not allow this action
+	KindNotFound     = "not_found"    // the tx refers to something that does not exist (yet)
+	KindConflict     = "conflict"     // the tx conflicts with the current state
+	KindInternal     = "internal"     // our store failed, this is not the fault of the tx
+)
+
+// ErrorCode documents one of the codes above
+type ErrorCode struct {
+	Code        tmsp.CodeType `json:"code"`
+	Name        string        `json:"name"`
+	Kind        string        `json:"kind"`
+	Description string        `json:"description"`
+}
+
+var errorCodes = []ErrorCode{
+	{CodeInvalidTx, "invalid_tx", KindInvalid, "The tx cannot be decoded, or its signature is invalid"},
+	{CodeUnknownAction, "unknown_action", KindInvalid, "The tx contains an action this app does not know"},
+	{CodeStorage, "storage", KindInternal, "Reading or writing the store failed"},
+	{CodeUnsigned, "unsigned", KindUnauthorized, "The action must be signed"},
+	{CodeNoSignerAccount, "no_signer_account", KindNotFound, "No account is controlled by the key that signed the tx"},
+	{CodeBadSequence, "bad_sequence", KindConflict, "The sequence is not the next one for this account (replay or out of order)"},
+
+	{CodeInvalidName, "invalid_name", KindInvalid, "The account name is empty, too long or has invalid characters"},
+	{CodeKeyHasAccount, "key_has_account", KindConflict, "The signing key already controls an account"},
+	{CodeKeyWasUsed, "key_was_used", KindConflict, "The signing key created an account before, and rotated away from it"},
+	{CodeNameTaken, "name_taken", KindConflict, "Another account already has this name"},
+	{CodeNoAccount, "no_account", KindNotFound, "No account with this id"},
+	{CodeBadProfile, "bad_profile", KindInvalid, "A profile field is too long or malformed"},
+
+	{CodeBadPostKey, "bad_post_key", KindInvalid, "The post key cannot be decoded"},
+	{CodeNoPost, "no_post", KindNotFound, "No post with this key or number"},
+	{CodePostRetracted, "post_retracted", KindConflict, "The post was retracted, so it cannot be edited, endorsed or replied to"},
+	{CodeAlreadyRetracted, "already_retracted", KindConflict, "The post was already retracted"},
+	{CodeOwnPost, "own_post", KindInvalid, "An account cannot endorse its own post"},
+	{CodeAlreadyEndorsed, "already_endorsed", KindConflict, "The account already endorsed this post"},
+	{CodeBadDigest, "bad_digest", KindInvalid, "The digest does not match the hash algorithm"},
+	{CodeAlreadyNotarized, "already_notarized", KindConflict, "The digest was already notarized"},
+	{CodeOwnAccount, "own_account", KindInvalid, "An account cannot follow or attest itself"},
+	{CodeTooManyFollowing, "too_many_following", KindConflict, "The account already follows the maximum number of accounts"},
+	{CodeAlreadyFollowing, "already_following", KindConflict, "The account already follows this account"},
+	{CodeNotFollowing, "not_following", KindNotFound, "The account does not follow this account"},
+	{CodeBadClaim, "bad_claim", KindInvalid, "The claim or value of the attestation is empty or too long"},
+	{CodeExpired, "expired", KindInvalid, "The attestation expires before the current block"},
+	{CodeNoAttestation, "no_attestation", KindNotFound, "No attestation with this subject and claim by this account"},
+	{CodeAlreadyRevoked, "already_revoked", KindConflict, "The attestation was already revoked, it cannot be revoked or attested again"},
+	{CodeBadCounterSig, "bad_counter_signature", KindUnauthorized, "The new key did not countersign the rotation"},
+	{CodeNewKeyUsed, "new_key_used", KindConflict, "The new key already controls or created an account"},
+	{CodeBadMembers, "bad_members", KindInvalid, "The members or threshold of the multisig account are invalid"},
+	{CodeNotMember, "not_member", KindUnauthorized, "The signer must be a member of the multisig account"},
+	{CodeMultisigExists, "multisig_exists", KindConflict, "An account with these members and threshold already exists"},
+	{CodeNoMultisig, "no_multisig", KindNotFound, "No multisig account with this id"},
+	{CodeBelowThreshold, "below_threshold", KindUnauthorized, "Not enough members signed, or a member signature is invalid"},
+	{CodeMultisigForbidden, "multisig_forbidden", KindInvalid, "This action cannot be done by a multisig account"},
+}
+
+// ErrorCodes returns all codes we return when rejecting a tx, ordered by code
+func ErrorCodes() []ErrorCode {
+	return append([]ErrorCode{}, errorCodes...)
+}
+
+// LookupErrorCode returns the documentation of this code, false if it is not one of ours
+func LookupErrorCode(code tmsp.CodeType) (ErrorCode, bool) {
+	for _, ec := range errorCodes {
+		if ec.Code == code {
+			return ec, true
+		}
+	}
+	return ErrorCode{}, false
+}
+
+// storageError reports a failure of the store
+func storageError(err error) tmsp.Result {
+	return tmsp.NewError(CodeStorage, err.Error())
+}
@@ -1,8 +1,6 @@
 package redux
 
 import (
-	"fmt"
-
 	"github.com/ethanfrey/signedpost/txn"
 	"github.com/ethanfrey/tenderize/sign"
 	merkle "github.com/tendermint/go-merkle"
@@ -11,7 +9,7 @@ import (
 
 // Service contains all static info to process transactions
 type Service struct {
-	// TODO: logger, block height
+	// TODO: logger
 	store       merkle.Tree
 	blockHeight uint64
 }
@@ -31,12 +29,9 @@ func (s *Service) GetHeight() uint64 {
 	return s.blockHeight
 }
 
-func (s *Service) SetHeight(h uint64) {
-	s.blockHeight = h
-}
-
-func (s *Service) Info() string {
-	return fmt.Sprintf("size:%v", s.store.Size())
+// SetBlock is called at the beginning of every block, so we tag all changes properly
+func (s *Service) SetBlock(height uint64) {
+	s.blockHeight = height
 }
 
 func (s *Service) Hash() []byte {
@@ -46,6 +41,15 @@ func (s *Service) Hash() []byte {
 	return s.store.Hash()
 }
 
+// Save persists the tree to its db and returns the new root hash
+// Only valid if the tree was created with a db
+func (s *Service) Save() []byte {
+	if s.store.Size() == 0 {
+		return nil
+	}
+	return s.store.Save()
+}
+
 func (s *Service) Copy() *Service {
 	return &Service{
 		store:       s.store.Copy(),
@@ -61,6 +65,30 @@ func (s *Service) Apply(tx sign.ValidatedAction) tmsp.Result {
 		return s.CreateAccount(action, tx.GetSigner())
 	case txn.AddPostAction:
 		return s.AppendPost(action, tx.GetSigner())
+	case txn.NotarizeAction:
+		return s.Notarize(action, tx.GetSigner())
+	case txn.EditPostAction:
+		return s.EditPost(action, tx.GetSigner())
+	case txn.RetractPostAction:
+		return s.RetractPost(action, tx.GetSigner())
+	case txn.RotateKeyAction:
+		return s.RotateKey(action, tx.GetSigner())
+	case txn.CreateMultisigAction:
+		return s.CreateMultisig(action, tx.GetSigner())
+	case txn.EndorsePostAction:
+		return s.EndorsePost(action, tx.GetSigner())
+	case txn.AttestIdentityAction:
+		return s.AttestIdentity(action, tx.GetSigner())
+	case txn.RevokeAttestationAction:
+		return s.RevokeAttestation(action, tx.GetSigner())
+	case txn.UpdateProfileAction:
+		return s.UpdateProfile(action, tx.GetSigner())
+	case txn.FollowAction:
+		return s.Follow(action, tx.GetSigner())
+	case txn.UnfollowAction:
+		return s.Unfollow(action, tx.GetSigner())
+	case txn.MultisigAction:
+		return s.ApplyMultisig(action)
 	}
-	return tmsp.NewError(tmsp.CodeType_BaseInvalidInput, "Unknown action")
+	return tmsp.NewError(CodeUnknownAction, "Unknown action")
 }
@@ -0,0 +1,49 @@
+package signedpost
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/types"
+)
+
+// BlockStore is the subset of tendermint's blockchain.BlockStore we need to replay blocks
+type BlockStore interface {
+	Height() int
+	LoadBlock(height int) *types.Block
+}
+
+// Replay feeds all blocks that tendermint has, but the app has not yet committed,
+// through AppendTx, EndBlock and Commit.  Before applying each block, we make sure
+// the app hash in its header matches our last commit, so we never build on a fork.
+func (app *Application) Replay(blocks BlockStore) error {
+	last := uint64(blocks.Height())
+	if app.last.Height > last {
+		return errors.Errorf("App at height %d is ahead of the chain at %d", app.last.Height, last)
+	}
+
+	for h := app.last.Height + 1; h <= last; h++ {
+		block := blocks.LoadBlock(int(h))
+		if block == nil {
+			return errors.Errorf("Missing block %d", h)
+		}
+		// the header of block h contains the app hash after block h-1
+		if !bytes.Equal(block.AppHash, app.last.Hash) {
+			return errors.Errorf("App hash mismatch before block %d: chain %X, app %X",
+				h, block.AppHash, app.last.Hash)
+		}
+
+		app.times.header(h, block.Time)
+		app.BeginBlock(h)
+		for _, tx := range block.Txs {
+			// invalid txs are part of the block as well, just like in consensus we ignore the result
+			app.AppendTx(tx)
+		}
+		app.EndBlock(h)
+		res := app.Commit()
+		if res.IsErr() {
+			return errors.Errorf("Commit block %d: %s", h, res.Log)
+		}
+	}
+	return nil
+}
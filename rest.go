@@ -3,63 +3,398 @@ package signedpost
 import (
 	"encoding/hex"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 
+	"github.com/ethanfrey/signedpost/redux"
+	"github.com/ethanfrey/signedpost/store"
 	"github.com/ethanfrey/signedpost/utils"
 	"github.com/ethanfrey/signedpost/view"
 )
 
 /*
 This file contains all REST API calls exposed by the app (simple json queries)
+
+They all read from a committed Snapshot, never from the tree modified by AppendTx.
+By default this is the last commit, but any query can pass ?height=N to read the
+state as of a past block (within the retention window set by KeepHistory)
 */
 
+// querySnapshot returns the snapshot for the height query param, or the latest if not given
+func (app *Application) querySnapshot(r *http.Request) (view.Snapshot, error) {
+	h := r.URL.Query().Get("height")
+	if h == "" {
+		return app.Snapshot(), nil
+	}
+	height, err := strconv.ParseUint(h, 10, 64)
+	if err != nil {
+		return view.Snapshot{}, errors.Wrap(err, "Invalid height")
+	}
+	return app.SnapshotAt(height)
+}
+
+const (
+	// defaultPageSize is the number of items in a listing if no limit is given
+	defaultPageSize = 100
+	// maxPageSize is the most items a client can request at once
+	maxPageSize = 1000
+	// defaultThreadDepth is the number of reply levels in a thread if no depth is given
+	defaultThreadDepth = 3
+	// maxThreadDepth is the most reply levels a client can request at once
+	maxThreadDepth = 10
+	// defaultThreadReplies is the number of replies per post in a thread if no limit is given
+	defaultThreadReplies = 10
+	// maxThreadNodes is the most posts in one thread response, however deep and wide it is
+	maxThreadNodes = 1000
+)
+
+// queryHideRetracted is true if ?retracted=hide asks to leave retracted posts out of a listing
+func queryHideRetracted(r *http.Request) bool {
+	return r.URL.Query().Get("retracted") == "hide"
+}
+
+// queryPage parses the ?limit=, ?after= and ?order= params for a listing
+func queryPage(r *http.Request) (store.Page, error) {
+	q := r.URL.Query()
+	page := store.Page{Limit: defaultPageSize}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return page, utils.BadRequest(errors.Errorf("Invalid limit, must be 1 to %d", maxPageSize))
+		}
+		page.Limit = limit
+	}
+	if a := q.Get("after"); a != "" {
+		after, err := hex.DecodeString(a)
+		if err != nil {
+			return page, errors.Wrap(err, "Invalid after")
+		}
+		page.After = after
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		page.Reverse = true
+	default:
+		return page, utils.BadRequest(errors.New("Invalid order, must be asc or desc"))
+	}
+	return page, nil
+}
+
 func (app *Application) SearchAccounts(rw http.ResponseWriter, r *http.Request) {
 	var accts *view.AccountList
-	var err error
+	snap, err := app.querySnapshot(r)
 	name := r.URL.Query().Get("username")
-	if name == "" {
-		accts, err = view.AllAccounts(app.commited.GetDB())
-	} else {
-		accts, err = view.AccountByName(app.commited.GetDB(), name)
+	if err == nil && name == "" {
+		var page store.Page
+		page, err = queryPage(r)
+		if err == nil {
+			accts, err = view.AllAccounts(snap, page)
+		}
+	} else if err == nil {
+		accts, err = view.AccountByName(snap, name)
 	}
 	utils.RenderQuery(rw, accts, err)
 }
 
 func (app *Application) AccountByKey(rw http.ResponseWriter, r *http.Request) {
 	var acct *view.Account
-	q := mux.Vars(r)["acct"]
-	key, err := hex.DecodeString(q)
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["acct"])
+	}
 	if err == nil {
-		acct, err = view.AccountByKey(app.commited.GetDB(), key)
+		acct, err = view.AccountByKey(snap, key, app.trusted)
+	}
+	utils.RenderQuery(rw, acct, err)
+}
+
+func (app *Application) MultisigForAccount(rw http.ResponseWriter, r *http.Request) {
+	var def *view.Multisig
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["acct"])
+	}
+	if err == nil {
+		def, err = view.MultisigForAccount(snap, key)
+	}
+	utils.RenderQuery(rw, def, err)
+}
+
+func (app *Application) AccountAttestations(rw http.ResponseWriter, r *http.Request) {
+	var atts *view.AttestationList
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["acct"])
+	}
+	if err == nil {
+		atts, err = view.AccountAttestations(snap, key, app.trusted)
+	}
+	utils.RenderQuery(rw, atts, err)
+}
+
+func (app *Application) AccountBySigner(rw http.ResponseWriter, r *http.Request) {
+	var acct *view.Account
+	var addr []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		addr, err = hex.DecodeString(mux.Vars(r)["addr"])
+	}
+	if err == nil {
+		acct, err = view.AccountBySigner(snap, addr, app.trusted)
 	}
 	utils.RenderQuery(rw, acct, err)
 }
 
 func (app *Application) PostByKey(rw http.ResponseWriter, r *http.Request) {
 	var post *view.Post
-	q := mux.Vars(r)["post"]
-	key, err := hex.DecodeString(q)
+	var key []byte
+	snap, err := app.querySnapshot(r)
 	if err == nil {
-		post, err = view.PostByKey(app.commited.GetDB(), key)
+		key, err = hex.DecodeString(mux.Vars(r)["post"])
+	}
+	if err == nil {
+		post, err = view.PostByKey(snap, key)
 	}
 	utils.RenderQuery(rw, post, err)
 }
 
 func (app *Application) PostsForAccount(rw http.ResponseWriter, r *http.Request) {
 	var posts *view.PostList
-	q := mux.Vars(r)["acct"]
-	key, err := hex.DecodeString(q)
+	var key []byte
+	var page store.Page
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["acct"])
+	}
+	if err == nil {
+		page, err = queryPage(r)
+	}
+	if err == nil {
+		posts, err = view.PostsForAccount(snap, key, page, queryHideRetracted(r))
+	}
+	utils.RenderQuery(rw, posts, err)
+}
+
+func (app *Application) AccountFeed(rw http.ResponseWriter, r *http.Request) {
+	var posts *view.PostList
+	var key []byte
+	var page store.Page
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["acct"])
+	}
+	if err == nil {
+		page, err = queryPage(r)
+	}
+	if err == nil {
+		posts, err = view.AccountFeed(snap, key, page, queryHideRetracted(r))
+	}
+	utils.RenderQuery(rw, posts, err)
+}
+
+// atomType is the content type of atom feeds
+const atomType = "application/atom+xml"
+
+// serverURL is the url of this server as the client reached it, for absolute links
+func serverURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// notModified sets the ETag of a response that only changes with the app hash,
+// and returns true (after writing 304) if the client already has this version
+func notModified(rw http.ResponseWriter, r *http.Request, snap view.Snapshot) bool {
+	etag := `"` + snapshotVersion(snap) + `"`
+	rw.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+func (app *Application) AccountAtom(rw http.ResponseWriter, r *http.Request) {
+	var feed *view.AtomFeed
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["acct"])
+	}
+	// only an existing account has a version to compare
+	if err == nil {
+		err = view.CheckAccount(snap, key)
+	}
+	if err == nil && notModified(rw, r, snap) {
+		return
+	}
+	if err == nil {
+		feed, err = view.AccountAtom(snap, key, serverURL(r), queryHideRetracted(r))
+	}
+	utils.RenderXML(rw, atomType, feed, err)
+}
+
+func (app *Application) GlobalAtom(rw http.ResponseWriter, r *http.Request) {
+	var feed *view.AtomFeed
+	snap, err := app.querySnapshot(r)
+	if err == nil && notModified(rw, r, snap) {
+		return
+	}
+	base, hide := serverURL(r), queryHideRetracted(r)
+	if err == nil {
+		feed = app.feeds.get(snap, base, hide)
+	}
+	if err == nil && feed == nil {
+		feed, err = view.GlobalAtom(snap, base, hide)
+		if err == nil {
+			app.feeds.put(snap, base, hide, feed)
+		}
+	}
+	utils.RenderXML(rw, atomType, feed, err)
+}
+
+// ErrorCodes documents the codes we return when rejecting a tx
+func (app *Application) ErrorCodes(rw http.ResponseWriter, r *http.Request) {
+	utils.RenderQuery(rw, redux.ErrorCodes(), nil)
+}
+
+func (app *Application) PostReplies(rw http.ResponseWriter, r *http.Request) {
+	var posts *view.PostList
+	var key []byte
+	var page store.Page
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["post"])
+	}
+	if err == nil {
+		page, err = queryPage(r)
+	}
 	if err == nil {
-		posts, err = view.PostsForAccount(app.commited.GetDB(), key)
+		posts, err = view.PostReplies(snap, key, page, queryHideRetracted(r))
 	}
 	utils.RenderQuery(rw, posts, err)
 }
 
+// PostThread returns the reply tree, ?depth= sets the levels and ?limit= the replies per post
+func (app *Application) PostThread(rw http.ResponseWriter, r *http.Request) {
+	var thread *view.Thread
+	var key []byte
+	var page store.Page
+	depth := defaultThreadDepth
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["post"])
+	}
+	if err == nil {
+		page, err = queryPage(r)
+	}
+	if r.URL.Query().Get("limit") == "" {
+		page.Limit = defaultThreadReplies
+	}
+	if d := r.URL.Query().Get("depth"); err == nil && d != "" {
+		depth, err = strconv.Atoi(d)
+		if err != nil || depth < 0 || depth > maxThreadDepth {
+			err = utils.BadRequest(errors.Errorf("Invalid depth, must be 0 to %d", maxThreadDepth))
+		}
+	}
+	if err == nil {
+		thread, err = view.PostThread(snap, key, depth, page.Limit, maxThreadNodes, queryHideRetracted(r))
+	}
+	utils.RenderQuery(rw, thread, err)
+}
+
+func (app *Application) PostRevisions(rw http.ResponseWriter, r *http.Request) {
+	var revs *view.RevisionList
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["post"])
+	}
+	if err == nil {
+		revs, err = view.PostRevisions(snap, key)
+	}
+	utils.RenderQuery(rw, revs, err)
+}
+
+func (app *Application) PostEndorsements(rw http.ResponseWriter, r *http.Request) {
+	var ends *view.EndorsementList
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["post"])
+	}
+	if err == nil {
+		ends, err = view.PostEndorsements(snap, key)
+	}
+	utils.RenderQuery(rw, ends, err)
+}
+
+func (app *Application) NotaryByDigest(rw http.ResponseWriter, r *http.Request) {
+	var notary *view.Notary
+	var digest []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		digest, err = hex.DecodeString(mux.Vars(r)["digest"])
+	}
+	if err == nil {
+		notary, err = view.NotaryByDigest(snap, digest)
+	}
+	utils.RenderQuery(rw, notary, err)
+}
+
+func (app *Application) AccountProof(rw http.ResponseWriter, r *http.Request) {
+	var proof *view.Proof
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["acct"])
+	}
+	if err == nil {
+		proof, err = view.AccountProof(snap, key)
+	}
+	utils.RenderQuery(rw, proof, err)
+}
+
+func (app *Application) PostProof(rw http.ResponseWriter, r *http.Request) {
+	var proof *view.Proof
+	var key []byte
+	snap, err := app.querySnapshot(r)
+	if err == nil {
+		key, err = hex.DecodeString(mux.Vars(r)["post"])
+	}
+	if err == nil {
+		proof, err = view.PostProof(snap, key)
+	}
+	utils.RenderQuery(rw, proof, err)
+}
+
 // AddQueryRoutes add all routes for reading the app state (unsigned)
 func (app *Application) AddQueryRoutes(r *mux.Router) {
 	r.HandleFunc("/accounts", app.SearchAccounts).Methods("GET")
 	r.HandleFunc("/accounts/{acct}", app.AccountByKey).Methods("GET")
 	r.HandleFunc("/accounts/{acct}/posts", app.PostsForAccount).Methods("GET")
+	r.HandleFunc("/accounts/{acct}/feed", app.AccountFeed).Methods("GET")
+	r.HandleFunc("/accounts/{acct}/feed.atom", app.AccountAtom).Methods("GET")
+	r.HandleFunc("/accounts/{acct}/proof", app.AccountProof).Methods("GET")
+	r.HandleFunc("/accounts/{acct}/multisig", app.MultisigForAccount).Methods("GET")
+	r.HandleFunc("/accounts/{acct}/attestations", app.AccountAttestations).Methods("GET")
+	r.HandleFunc("/signers/{addr}", app.AccountBySigner).Methods("GET")
 	r.HandleFunc("/posts/{post}", app.PostByKey).Methods("GET")
+	r.HandleFunc("/posts/{post}/proof", app.PostProof).Methods("GET")
+	r.HandleFunc("/posts/{post}/revisions", app.PostRevisions).Methods("GET")
+	r.HandleFunc("/posts/{post}/replies", app.PostReplies).Methods("GET")
+	r.HandleFunc("/posts/{post}/thread", app.PostThread).Methods("GET")
+	r.HandleFunc("/posts/{post}/endorsements", app.PostEndorsements).Methods("GET")
+	r.HandleFunc("/notary/{digest}", app.NotaryByDigest).Methods("GET")
+	r.HandleFunc("/stream", app.Stream).Methods("GET")
+	r.HandleFunc("/feed.atom", app.GlobalAtom).Methods("GET")
+	r.HandleFunc("/codes", app.ErrorCodes).Methods("GET")
 }
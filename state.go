@@ -0,0 +1,88 @@
+package signedpost
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/ethanfrey/signedpost/utils"
+	wutil "github.com/ethanfrey/tenderize/wire"
+	dbm "github.com/tendermint/go-db"
+)
+
+// commitStateKey is where we store the CommitState in the db, next to the merkle nodes
+// (which are all stored under their 20 byte hash, so this cannot collide)
+var commitStateKey = []byte("signedpost/commit")
+
+// CommitState records the last block committed by the app,
+// so we can resume from the same root after a restart
+type CommitState struct {
+	Height uint64
+	Hash   []byte
+}
+
+// LoadCommitState reads the last commit from the db.
+// If nothing was ever committed, returns an empty state
+func LoadCommitState(db dbm.DB) (CommitState, error) {
+	state := CommitState{}
+	data := db.Get(commitStateKey)
+	if len(data) == 0 {
+		return state, nil
+	}
+	err := wutil.FromBinary(data, &state)
+	return state, err
+}
+
+// Save writes the commit state to disk (synchronously, as we rely on it to restart)
+func (s CommitState) Save(db dbm.DB) error {
+	data, err := wutil.ToBinary(s)
+	if err != nil {
+		return err
+	}
+	db.SetSync(commitStateKey, data)
+	return nil
+}
+
+// rootKey is where we store the CommitState for a past height, for historical queries
+func rootKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("signedpost/root/%d", height))
+}
+
+// saveRoot stores this commit under its height
+func (s CommitState) saveRoot(db dbm.DB) error {
+	data, err := wutil.ToBinary(s)
+	if err != nil {
+		return err
+	}
+	db.Set(rootKey(s.Height), data)
+	return nil
+}
+
+// loadRoot finds the commit for a past height, if we still have it
+func loadRoot(db dbm.DB, height uint64) (CommitState, error) {
+	state := CommitState{}
+	data := db.Get(rootKey(height))
+	if len(data) == 0 {
+		return state, utils.BadRequest(errors.Errorf("Height %d not available", height))
+	}
+	err := wutil.FromBinary(data, &state)
+	return state, err
+}
+
+// AppInfo is returned (as json) by Info, so tendermint and operators
+// can see how far the app got
+type AppInfo struct {
+	Height  uint64 `json:"height"`
+	AppHash string `json:"app_hash"`
+	Size    int    `json:"size"`
+}
+
+// Info returns the summary of this commit for the given tree size
+func (s CommitState) Info(size int) AppInfo {
+	return AppInfo{
+		Height:  s.Height,
+		AppHash: hex.EncodeToString(s.Hash),
+		Size:    size,
+	}
+}
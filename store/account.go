@@ -11,9 +11,22 @@ import (
 // Account is a named account that can publish blog entries
 // This can be serialized with go-wire
 type Account struct {
-	ID         []byte
+	ID         []byte // never changes: address of the creating key, or hash of a multisig (see NewMultisig)
 	Name       string // this is a name to search for
 	EntryCount int64  // total number of entries (de-normalize for speed)
+	Sequence   int64  // sequence of the last tx signed by this account, to prevent replays
+	Signer     []byte // address of the key that controls the account now (see Signer)
+	Followers  int64  // number of accounts following this one
+	Following  int64  // number of accounts this one follows
+	Profile    Profile
+}
+
+// Profile is optional public information about the account owner, set by UpdateProfileAction
+type Profile struct {
+	DisplayName string
+	Bio         string
+	Website     string
+	AvatarHash  []byte
 }
 
 // AccountKey wraps the immutible ID
@@ -38,7 +51,7 @@ func (k AccountKey) Range() (min, max mom.Key) {
 // NewAccount generates the account id from a public key
 func NewAccount(pk crypto.PubKey, name string) Account {
 	addr := pk.Address()
-	return Account{ID: addr, Name: name}
+	return Account{ID: addr, Name: name, Signer: addr}
 }
 
 // AccountMatchesName returns a mom.Query filter for exact matches of account name
@@ -58,16 +71,39 @@ func AccountContainsName(name string) func(mom.Model) bool {
 	}
 }
 
-// FindAccount looks up by primary key (index scan)
+// FindAccount looks up the account controlled by this key (via the Signer index)
 // Error on storage error, if no match, returns nil
 func FindAccount(store merkle.Tree, pk crypto.PubKey) (*Account, error) {
-	key := NewAccount(pk, "").Key()
-	model, err := mom.Load(store, key)
+	model, err := mom.Load(store, SignerKey{Address: pk.Address()})
 	if err != nil || model == nil {
 		return nil, err
 	}
-	res := model.(Account)
-	return &res, nil
+	return loadAccount(store, model.(Signer).Account)
+}
+
+// FindAccountByID looks up the account with this id
+// Error on storage error, if no match, returns nil
+func FindAccountByID(store merkle.Tree, id []byte) (*Account, error) {
+	return loadAccount(store, AccountKey{ID: id})
+}
+
+// AccountExists checks if any account was created with this id
+func AccountExists(store merkle.Tree, id []byte) (bool, error) {
+	acct, err := FindAccountByID(store, id)
+	return acct != nil, err
+}
+
+// PageAccounts returns one page of all accounts, along with the cursor for the next page
+func PageAccounts(store merkle.Tree, page Page) ([]Account, []byte, error) {
+	models, next, err := ListPage(store, mom.Query{Key: AccountKey{}}, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := make([]Account, len(models))
+	for i := range models {
+		res[i] = models[i].(Account)
+	}
+	return res, next, nil
 }
 
 // ListAccounts makes a search over all accounts, and casts them to the proper type
@@ -35,6 +35,7 @@ func TestAccount(t *testing.T) {
 	updated, err := mom.Save(tree, acct)
 	assert.False(updated)
 	require.Nil(err)
+	require.Nil(IndexSigner(tree, acct))
 
 	// on update
 	acct.Name = "Demoed"
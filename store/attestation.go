@@ -0,0 +1,75 @@
+package store
+
+import (
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// Attestation is a claim by one account (the attester, eg. a notary) about the
+// real-world identity of another account (the subject), eg. the legal name or an email hash
+type Attestation struct {
+	Subject      mom.Key
+	Attester     mom.Key
+	Claim        string // type of the claim, eg. "legal_name"
+	Value        string
+	IssuedBlock  uint64
+	ExpiresBlock uint64 // 0 if it never expires
+	Revoked      bool
+	RevokedBlock uint64
+}
+
+// AttestationKey is the index of the Attestation structure
+type AttestationKey struct {
+	Subject  mom.Key
+	Attester mom.Key
+	Claim    string
+}
+
+// Key returns the index of the Attestation (subject, attester, claim),
+// so an attester has one claim of each type per subject
+func (a Attestation) Key() mom.Key {
+	return AttestationKey{
+		Subject:  a.Subject,
+		Attester: a.Attester,
+		Claim:    a.Claim,
+	}
+}
+
+// Range contains all attestations about the subject if Attester is not set
+func (k AttestationKey) Range() (mom.Key, mom.Key) {
+	if k.Attester != nil {
+		return k, k
+	}
+	min := AttestationKey{Subject: k.Subject, Attester: AccountKey{ID: minAccountID}}
+	max := AttestationKey{Subject: k.Subject, Attester: AccountKey{ID: maxAccountID}}
+	return min, max
+}
+
+// IsValid checks if the attestation is neither revoked nor expired at this height
+func (a Attestation) IsValid(height uint64) bool {
+	return !a.Revoked && (a.ExpiresBlock == 0 || height < a.ExpiresBlock)
+}
+
+// FindAttestation looks up the claim of this attester about the subject
+// Error on storage error, if no match, returns nil
+func FindAttestation(store merkle.Tree, subject, attester mom.Key, claim string) (*Attestation, error) {
+	model, err := mom.Load(store, AttestationKey{Subject: subject, Attester: attester, Claim: claim})
+	if err != nil || model == nil {
+		return nil, err
+	}
+	res := model.(Attestation)
+	return &res, nil
+}
+
+// ListAttestations returns all attestations about the subject, including revoked and expired ones
+func ListAttestations(store merkle.Tree, subject mom.Key) ([]Attestation, error) {
+	models, err := mom.List(store, mom.Query{Key: AttestationKey{Subject: subject}})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Attestation, len(models))
+	for i := range models {
+		res[i] = models[i].(Attestation)
+	}
+	return res, nil
+}
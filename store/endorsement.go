@@ -0,0 +1,55 @@
+package store
+
+import (
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// Endorsement is the signature of another account on an existing post (eg. the second party of an agreement)
+type Endorsement struct {
+	Post           mom.Key // the PostKey of the endorsed post
+	Account        mom.Key // the endorsing account
+	Revision       int64   // the revision of the post that was endorsed (0 for the original)
+	Comment        string
+	PublishedBlock uint64
+}
+
+// EndorsementKey is the index of the Endorsement structure
+type EndorsementKey struct {
+	Post    mom.Key
+	Account mom.Key
+}
+
+// Key returns the index of the Endorsement (post, account), so each account can endorse a post once
+func (e Endorsement) Key() mom.Key {
+	return EndorsementKey{
+		Post:    e.Post,
+		Account: e.Account,
+	}
+}
+
+// Range contains all endorsements of the post if Account is not set
+func (k EndorsementKey) Range() (mom.Key, mom.Key) {
+	min, max := k, k
+	min.Post, max.Post = k.Post.Range()
+
+	acct := k.Account
+	if acct == nil {
+		acct = AccountKey{}
+	}
+	min.Account, max.Account = acct.Range()
+	return min, max
+}
+
+// ListEndorsements returns all endorsements of the post with this key
+func ListEndorsements(store merkle.Tree, post mom.Key) ([]Endorsement, error) {
+	models, err := mom.List(store, mom.Query{Key: EndorsementKey{Post: post}})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Endorsement, len(models))
+	for i := range models {
+		res[i] = models[i].(Endorsement)
+	}
+	return res, nil
+}
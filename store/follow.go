@@ -0,0 +1,231 @@
+package store
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/pkg/errors"
+	"github.com/tendermint/go-merkle"
+)
+
+// Follow records that one account follows the posts of another
+type Follow struct {
+	Follower mom.Key
+	Followed mom.Key
+	Block    uint64 // height when the follow was added
+}
+
+// FollowKey is the index of the Follow structure
+type FollowKey struct {
+	Follower mom.Key
+	Followed mom.Key
+}
+
+// Key returns the index of the Follow (follower, followed)
+func (f Follow) Key() mom.Key {
+	return FollowKey{
+		Follower: f.Follower,
+		Followed: f.Followed,
+	}
+}
+
+// Range contains all accounts the follower follows if Followed is not set
+func (k FollowKey) Range() (mom.Key, mom.Key) {
+	min, max := k, k
+	min.Follower, max.Follower = k.Follower.Range()
+
+	followed := k.Followed
+	if followed == nil {
+		followed = AccountKey{}
+	}
+	min.Followed, max.Followed = followed.Range()
+	return min, max
+}
+
+// FindFollow returns the follow of follower on followed, nil if there is none
+func FindFollow(store merkle.Tree, follower, followed mom.Key) (*Follow, error) {
+	model, err := mom.Load(store, FollowKey{Follower: follower, Followed: followed})
+	if err != nil || model == nil {
+		return nil, err
+	}
+	res := model.(Follow)
+	return &res, nil
+}
+
+// RemoveFollow deletes the follow of follower on followed
+func RemoveFollow(store merkle.Tree, follower, followed mom.Key) error {
+	key, err := mom.KeyToBytes(FollowKey{Follower: follower, Followed: followed})
+	if err != nil {
+		return err
+	}
+	store.Remove(key)
+	return nil
+}
+
+// ListFollowing returns all accounts the follower follows
+func ListFollowing(store merkle.Tree, follower mom.Key) ([]Follow, error) {
+	models, err := mom.List(store, mom.Query{Key: FollowKey{Follower: follower}})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Follow, len(models))
+	for i := range models {
+		res[i] = models[i].(Follow)
+	}
+	return res, nil
+}
+
+// feedItem is a post along with its key, which breaks ties between posts of the same block
+type feedItem struct {
+	post Post
+	key  []byte
+}
+
+// newerFirst sorts feed items by block height, then key, descending
+type newerFirst []feedItem
+
+func (f newerFirst) Len() int      { return len(f) }
+func (f newerFirst) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f newerFirst) Less(i, j int) bool {
+	if f[i].post.PublishedBlock != f[j].post.PublishedBlock {
+		return f[i].post.PublishedBlock > f[j].post.PublishedBlock
+	}
+	return bytes.Compare(f[i].key, f[j].key) > 0
+}
+
+// feedSource walks the posts of one followed account, newest first, one at a time
+type feedSource struct {
+	query mom.Query
+	after []byte    // key of the last post taken, or where to start
+	head  *feedItem // the next post of this account, nil at the end
+}
+
+func (f *feedSource) advance(store merkle.Tree) error {
+	f.head = nil
+	posts, _, err := ListPage(store, f.query, Page{Limit: 1, After: f.after, Reverse: true})
+	if err != nil || len(posts) == 0 {
+		return err
+	}
+	key, err := mom.KeyToBytes(posts[0].Key())
+	if err != nil {
+		return err
+	}
+	f.head = &feedItem{post: posts[0].(Post), key: key}
+	f.after = key
+	return nil
+}
+
+// startAfter returns the key of the oldest post of the account that comes before the cursor in the feed,
+// so a reverse range from there only has posts after the cursor. Posts are numbered in block order,
+// so we can search for it
+func startAfter(store merkle.Tree, account mom.Key, cursor feedItem) ([]byte, error) {
+	model, err := mom.Load(store, account)
+	if err != nil || model == nil {
+		return nil, err
+	}
+	count := int(model.(Account).EntryCount)
+	var failed error
+	first := sort.Search(count, func(i int) bool {
+		post, err := mom.Load(store, PostKey{Account: account, Number: int64(i + 1)})
+		if err != nil || post == nil {
+			failed = errors.Errorf("Missing post %d of %d", i+1, count)
+			return true
+		}
+		key, err := mom.KeyToBytes(post.Key())
+		if err != nil {
+			failed = err
+			return true
+		}
+		// is this post (or the cursor itself) not after the cursor
+		return !newerFirst{cursor, {post: post.(Post), key: key}}.Less(0, 1)
+	})
+	if failed != nil {
+		return nil, failed
+	}
+	return mom.KeyToBytes(PostKey{Account: account, Number: int64(first + 1)})
+}
+
+// PageFeed returns one page of the posts of all accounts the follower follows that pass the filter,
+// newest first (by block height), along with the cursor for the next page.
+// page.After is the key of the last post of the previous page, page.Reverse is ignored
+func PageFeed(store merkle.Tree, follower mom.Key, filter func(mom.Model) bool, page Page) ([]Post, []byte, error) {
+	following, err := ListFollowing(store, follower)
+	if err != nil {
+		return nil, nil, err
+	}
+	accounts := make([]mom.Key, len(following))
+	for i, f := range following {
+		accounts[i] = f.Followed
+	}
+	return PageNewest(store, accounts, filter, page)
+}
+
+// PageNewest returns one page of the posts of these accounts that pass the filter, newest first
+// (by block height), along with the cursor for the next page. It merges the newest posts of each
+// account, so it only loads the posts of the page (and one more per account).
+// page.After is the key of the last post of the previous page, page.Reverse is ignored
+func PageNewest(store merkle.Tree, accounts []mom.Key, filter func(mom.Model) bool, page Page) ([]Post, []byte, error) {
+	var cursor *feedItem
+	if len(page.After) > 0 {
+		key, err := mom.KeyFromBytes(page.After)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		model, err := mom.Load(store, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		post, ok := model.(Post)
+		if !ok {
+			return nil, nil, ErrInvalidCursor
+		}
+		cursor = &feedItem{post: post, key: page.After}
+	}
+
+	// each account starts right after the cursor, at its newest post otherwise
+	sources := make([]*feedSource, len(accounts))
+	for i, acct := range accounts {
+		var err error
+		src := &feedSource{query: mom.Query{Key: PostKey{Account: acct}, Filter: filter}}
+		if cursor != nil {
+			src.after, err = startAfter(store, acct, *cursor)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if err = src.advance(store); err != nil {
+			return nil, nil, err
+		}
+		sources[i] = src
+	}
+
+	// merge them, taking one more than fits to know if there is another page
+	items := []feedItem{}
+	for page.Limit == 0 || len(items) <= page.Limit {
+		var newest *feedSource
+		for _, src := range sources {
+			if src.head != nil && (newest == nil || newerFirst{*src.head, *newest.head}.Less(0, 1)) {
+				newest = src
+			}
+		}
+		if newest == nil {
+			break
+		}
+		items = append(items, *newest.head)
+		if err := newest.advance(store); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var next []byte
+	if page.Limit > 0 && len(items) > page.Limit {
+		items = items[:page.Limit]
+		next = items[page.Limit-1].key
+	}
+	res := make([]Post, len(items))
+	for i := range items {
+		res[i] = items[i].post
+	}
+	return res, next, nil
+}
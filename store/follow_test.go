@@ -0,0 +1,95 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
+	merkle "github.com/tendermint/go-merkle"
+)
+
+func TestPageFeed(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	reader := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), "Reader")
+	alice := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), "Alice")
+	bob := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), "Bob")
+	stranger := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), "Stranger")
+
+	// alice posts at 1, 4, 5; bob at 2, 3, 5 and the stranger (not followed) at every height
+	save := func(acct Account, number int64, block uint64) {
+		_, err := mom.Save(tree, Post{Account: acct.Key(), Number: number, PublishedBlock: block})
+		require.Nil(err)
+	}
+	for i, block := range []uint64{1, 4, 5} {
+		save(alice, int64(i+1), block)
+	}
+	for i, block := range []uint64{2, 3, 5} {
+		save(bob, int64(i+1), block)
+	}
+	for i := int64(1); i <= 5; i++ {
+		save(stranger, i, uint64(i))
+	}
+	alice.EntryCount, bob.EntryCount, stranger.EntryCount = 3, 3, 5
+	for _, acct := range []Account{reader, alice, bob, stranger} {
+		_, err := mom.Save(tree, acct)
+		require.Nil(err)
+	}
+	for _, followed := range []Account{alice, bob} {
+		_, err := mom.Save(tree, Follow{Follower: reader.Key(), Followed: followed.Key()})
+		require.Nil(err)
+	}
+	following, err := ListFollowing(tree, reader.Key())
+	require.Nil(err)
+	assert.Equal(2, len(following))
+
+	// no limit returns all, newest first
+	posts, next, err := PageFeed(tree, reader.Key(), nil, Page{})
+	require.Nil(err)
+	assert.Nil(next)
+	blocks := []uint64{}
+	for _, p := range posts {
+		assert.NotEqual(stranger.Key(), p.Account)
+		blocks = append(blocks, p.PublishedBlock)
+	}
+	assert.Equal([]uint64{5, 5, 4, 3, 2, 1}, blocks)
+
+	// pages pick up right after the cursor, even within one block
+	page := Page{Limit: 1}
+	for i, expected := range posts {
+		got, next, err := PageFeed(tree, reader.Key(), nil, page)
+		require.Nil(err)
+		if assert.Equal(1, len(got)) {
+			assert.Equal(expected, got[0])
+		}
+		if i == len(posts)-1 {
+			assert.Nil(next)
+		} else {
+			require.NotNil(next)
+		}
+		page.After = next
+	}
+
+	// a page that starts inside one block still takes the older posts of the other accounts
+	got, _, err := PageFeed(tree, reader.Key(), nil, Page{Limit: 2, After: mustKey(posts[2].Key())})
+	require.Nil(err)
+	require.Equal(2, len(got))
+	assert.Equal(posts[3:5], got)
+
+	// and unfollowing removes the posts
+	require.Nil(RemoveFollow(tree, reader.Key(), bob.Key()))
+	posts, _, err = PageFeed(tree, reader.Key(), nil, Page{})
+	require.Nil(err)
+	assert.Equal(3, len(posts))
+}
+
+func mustKey(key mom.Key) []byte {
+	res, err := mom.KeyToBytes(key)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
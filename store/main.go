@@ -14,5 +14,6 @@ var (
 
 func init() {
 	// IMPORTANT: you must call this in the init, so all serialization works
-	mom.RegisterModels(Account{}, Post{})
+	mom.RegisterModels(Account{}, Post{}, Notary{}, AccountName{}, NameIndex{}, PostRevision{}, Retraction{}, Signer{}, Multisig{}, Endorsement{},
+		Attestation{}, Reply{}, Follow{})
 }
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"bytes"
+	"sort"
+
+	"golang.org/x/crypto/ripemd160"
+
+	"github.com/ethanfrey/tenderize/mom"
+	wutil "github.com/ethanfrey/tenderize/wire"
+	"github.com/tendermint/go-merkle"
+)
+
+// Multisig defines an account that needs Threshold of the Members to sign for it.
+// The account itself is a normal Account, but with no Signer
+type Multisig struct {
+	Account   mom.Key
+	Threshold int64
+	Members   [][]byte // addresses of the member keys, sorted
+}
+
+// MultisigKey is the index of the Multisig structure
+type MultisigKey struct {
+	Account mom.Key
+}
+
+// Key returns the account this definition belongs to
+func (m Multisig) Key() mom.Key {
+	return MultisigKey{Account: m.Account}
+}
+
+// Range only supports lookup of one account
+func (k MultisigKey) Range() (mom.Key, mom.Key) {
+	return k, k
+}
+
+// multisigID is hashed to get the account id, so it is defined by the threshold and members
+type multisigID struct {
+	Type      string
+	Threshold int64
+	Members   [][]byte
+}
+
+// NewMultisig creates the account and its definition. The members are sorted,
+// so the same threshold and members always give the same account id
+func NewMultisig(name string, threshold int64, members [][]byte) (Account, Multisig, error) {
+	sorted := make([][]byte, len(members))
+	copy(sorted, members)
+	sort.Sort(byteSlices(sorted))
+
+	data, err := wutil.ToBinary(multisigID{Type: "multisig", Threshold: threshold, Members: sorted})
+	if err != nil {
+		return Account{}, Multisig{}, err
+	}
+	hasher := ripemd160.New()
+	hasher.Write(data)
+
+	acct := Account{ID: hasher.Sum(nil), Name: name}
+	def := Multisig{Account: acct.Key(), Threshold: threshold, Members: sorted}
+	return acct, def, nil
+}
+
+// IsMember checks if the key with this address is one of the members
+func (m Multisig) IsMember(addr []byte) bool {
+	for _, member := range m.Members {
+		if bytes.Equal(member, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindMultisig looks up the definition of a multisig account
+// Error on storage error, if no match (or a normal account), returns nil
+func FindMultisig(store merkle.Tree, acct mom.Key) (*Multisig, error) {
+	model, err := mom.Load(store, MultisigKey{Account: acct})
+	if err != nil || model == nil {
+		return nil, err
+	}
+	res := model.(Multisig)
+	return &res, nil
+}
+
+type byteSlices [][]byte
+
+func (b byteSlices) Len() int           { return len(b) }
+func (b byteSlices) Less(i, j int) bool { return bytes.Compare(b[i], b[j]) < 0 }
+func (b byteSlices) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
+)
+
+func TestMultisigID(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	a := crypto.GenPrivKeyEd25519().PubKey().Address()
+	b := crypto.GenPrivKeyEd25519().PubKey().Address()
+	c := crypto.GenPrivKeyEd25519().PubKey().Address()
+
+	acct, def, err := NewMultisig("Legal", 2, [][]byte{a, b, c})
+	require.Nil(err)
+	assert.Equal(accountIDLength, len(acct.ID))
+	assert.Nil(acct.Signer)
+	assert.Equal(acct.Key(), def.Account)
+	assert.True(def.IsMember(b))
+	assert.False(def.IsMember(acct.ID))
+
+	// the order of members doesn't matter, the threshold and members do
+	same, _, err := NewMultisig("Other", 2, [][]byte{c, a, b})
+	require.Nil(err)
+	assert.Equal(acct.ID, same.ID)
+	other, _, err := NewMultisig("Legal", 1, [][]byte{a, b, c})
+	require.Nil(err)
+	assert.NotEqual(acct.ID, other.ID)
+	other, _, err = NewMultisig("Legal", 2, [][]byte{a, b})
+	require.Nil(err)
+	assert.NotEqual(acct.ID, other.ID)
+}
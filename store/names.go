@@ -0,0 +1,127 @@
+package store
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// nameIndexLength is the number of bytes of the lower-case name we index for search
+const nameIndexLength = 32
+
+// AccountName maps a unique username to the account, for uniqueness checks and exact lookup
+type AccountName struct {
+	Name    string
+	Account mom.Key
+}
+
+// AccountNameKey is the index of the AccountName structure
+type AccountNameKey struct {
+	Name string
+}
+
+// Key returns the name, which is unique
+func (n AccountName) Key() mom.Key {
+	return AccountNameKey{Name: n.Name}
+}
+
+// Range only supports exact lookup, use NameIndexKey for searches
+func (k AccountNameKey) Range() (mom.Key, mom.Key) {
+	return k, k
+}
+
+// NameIndex is a case-insensitive search index over account names.
+// The name is stored in a fixed-length array (without go-wire length prefix),
+// so the keys for all names with a common prefix are next to each other in the tree
+type NameIndex struct {
+	Name [nameIndexLength]byte
+	ID   []byte
+}
+
+// NameIndexKey is the index of the NameIndex structure
+type NameIndexKey struct {
+	Name [nameIndexLength]byte
+	ID   []byte
+}
+
+// Key returns the (lower-case) name along with the account id
+func (n NameIndex) Key() mom.Key {
+	return NameIndexKey{Name: n.Name, ID: n.ID}
+}
+
+// Range returns just this key if ID is set, otherwise all names starting with the given Name
+// (which is zero-padded, zero never appears in a valid name)
+func (k NameIndexKey) Range() (mom.Key, mom.Key) {
+	if len(k.ID) == accountIDLength {
+		return k, k
+	}
+	min := NameIndexKey{Name: k.Name, ID: minAccountID}
+	max := NameIndexKey{Name: k.Name, ID: maxAccountID}
+	size := bytes.IndexByte(k.Name[:], 0)
+	if size >= 0 {
+		copy(max.Name[size:], bytes.Repeat([]byte{255}, nameIndexLength-size))
+	}
+	return min, max
+}
+
+// searchName returns the lower-case name padded (or cut) to the index length
+func searchName(name string) (res [nameIndexLength]byte) {
+	copy(res[:], strings.ToLower(name))
+	return res
+}
+
+// IndexAccount stores the indexes needed to look up a new account by name
+func IndexAccount(store merkle.Tree, acct Account) error {
+	_, err := mom.Save(store, AccountName{Name: acct.Name, Account: acct.Key()})
+	if err != nil {
+		return err
+	}
+	_, err = mom.Save(store, NameIndex{Name: searchName(acct.Name), ID: acct.ID})
+	return err
+}
+
+// FindAccountByName looks up the account with exactly this name
+// Error on storage error, if no match, returns nil
+func FindAccountByName(store merkle.Tree, name string) (*Account, error) {
+	model, err := mom.Load(store, AccountNameKey{Name: name})
+	if err != nil || model == nil {
+		return nil, err
+	}
+	return loadAccount(store, model.(AccountName).Account)
+}
+
+// SearchAccounts returns all accounts whose name starts with prefix (ignoring case)
+// note an empty response returns no error
+func SearchAccounts(store merkle.Tree, prefix string) ([]Account, error) {
+	query := mom.Query{
+		Key: NameIndexKey{Name: searchName(prefix)},
+	}
+	models, err := mom.List(store, query)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Account, 0, len(models))
+	for _, m := range models {
+		acct, err := loadAccount(store, AccountKey{ID: m.(NameIndex).ID})
+		if err != nil {
+			return nil, err
+		}
+		// the index only holds the first bytes of long names, so check the rest
+		if acct != nil && strings.HasPrefix(strings.ToLower(acct.Name), strings.ToLower(prefix)) {
+			res = append(res, *acct)
+		}
+	}
+	return res, nil
+}
+
+// loadAccount finds the account by key, returns nil if missing
+func loadAccount(store merkle.Tree, key mom.Key) (*Account, error) {
+	model, err := mom.Load(store, key)
+	if err != nil || model == nil {
+		return nil, err
+	}
+	res := model.(Account)
+	return &res, nil
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
+	merkle "github.com/tendermint/go-merkle"
+)
+
+func TestNameIndex(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	// empty searches
+	match, err := FindAccountByName(tree, "Demo")
+	assert.Nil(err)
+	assert.Nil(match)
+	matches, err := SearchAccounts(tree, "de")
+	assert.Nil(err)
+	assert.Equal(0, len(matches))
+
+	names := []string{"Demo", "demon", "Dem", "Alice", "ThisIsAVeryLongNameThatIsCutInTheIndex"}
+	for _, name := range names {
+		acct := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), name)
+		_, err := mom.Save(tree, acct)
+		require.Nil(err)
+		require.Nil(IndexAccount(tree, acct))
+	}
+
+	// exact matches are case-sensitive
+	match, err = FindAccountByName(tree, "Demo")
+	assert.Nil(err)
+	if assert.NotNil(match) {
+		assert.Equal("Demo", match.Name)
+	}
+	match, err = FindAccountByName(tree, "demo")
+	assert.Nil(err)
+	assert.Nil(match)
+
+	cases := []struct {
+		prefix string
+		count  int
+	}{
+		{"", 5},
+		{"d", 3},
+		{"DEMO", 2},
+		{"demon", 1},
+		{"demons", 0},
+		{"al", 1},
+		{"b", 0},
+		{"thisisaverylongnamethatiscutintheindex", 1},
+		{"thisisaverylongnamethatiscutintheend", 0},
+	}
+	for _, tc := range cases {
+		matches, err = SearchAccounts(tree, tc.prefix)
+		assert.Nil(err)
+		assert.Equal(tc.count, len(matches), tc.prefix)
+	}
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// Notary records when a document digest was first notarized, and by whom
+type Notary struct {
+	Digest         []byte
+	Algorithm      string
+	Title          string
+	Account        mom.Key
+	PublishedBlock uint64
+}
+
+// NotaryKey is the index of the Notary structure
+type NotaryKey struct {
+	Digest []byte
+}
+
+// Key returns the digest, which is unique
+func (n Notary) Key() mom.Key {
+	return NotaryKey{Digest: n.Digest}
+}
+
+// Range only supports lookup of one digest, as they have different lengths
+func (k NotaryKey) Range() (mom.Key, mom.Key) {
+	return k, k
+}
+
+// FindNotary looks up by digest
+// Error on storage error, if no match, returns nil
+func FindNotary(store merkle.Tree, digest []byte) (*Notary, error) {
+	model, err := mom.Load(store, NotaryKey{Digest: digest})
+	if err != nil || model == nil {
+		return nil, err
+	}
+	res := model.(Notary)
+	return &res, nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
+	merkle "github.com/tendermint/go-merkle"
+)
+
+func TestNotary(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	pub := crypto.GenPrivKeyEd25519().PubKey()
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	acct := NewAccount(pub, "Notary")
+	digest := sha256.Sum256([]byte("my contract"))
+	other := sha256.Sum256([]byte("another contract"))
+
+	// nothing there yet
+	match, err := FindNotary(tree, digest[:])
+	assert.Nil(err)
+	assert.Nil(match)
+
+	n := Notary{
+		Digest:         digest[:],
+		Algorithm:      "sha256",
+		Title:          "Contract",
+		Account:        acct.Key(),
+		PublishedBlock: 12,
+	}
+	updated, err := mom.Save(tree, n)
+	require.Nil(err, "%+v", err)
+	assert.False(updated)
+
+	// find it by digest
+	match, err = FindNotary(tree, digest[:])
+	assert.Nil(err)
+	if assert.NotNil(match) {
+		assert.Equal(n.Title, match.Title)
+		assert.Equal(n.Algorithm, match.Algorithm)
+		assert.EqualValues(n.Account, match.Account)
+		assert.Equal(n.PublishedBlock, match.PublishedBlock)
+	}
+
+	// but not any other one
+	match, err = FindNotary(tree, other[:])
+	assert.Nil(err)
+	assert.Nil(match)
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// ErrInvalidCursor is returned if page.After is not a key we handed out as a cursor
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// Page selects one window of a range query, so we never load more than we return
+type Page struct {
+	Limit   int    // max number of items, 0 for no limit
+	After   []byte // cursor: only return items after this key (in the iteration order)
+	Reverse bool   // iterate from the highest key down
+}
+
+// ListPage returns up to page.Limit models that match the query, starting after page.After.
+// The order comes from page.Reverse (overriding q.Reverse).
+// next is the key of the last model returned if there are more, nil at the end of the range
+func ListPage(store merkle.Tree, q mom.Query, page Page) (models []mom.Model, next []byte, err error) {
+	start, end, err := mom.ByteRange(q.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	// skip straight to the cursor (which is itself excluded below)
+	if len(page.After) > 0 {
+		if !page.Reverse && bytes.Compare(page.After, start) > 0 {
+			start = page.After
+		} else if page.Reverse && bytes.Compare(page.After, end) < 0 {
+			end = page.After
+		}
+	}
+
+	models = []mom.Model{}
+	var last []byte
+	store.IterateRange(start, end, !page.Reverse, func(k []byte, v []byte) bool {
+		if bytes.Equal(k, page.After) {
+			return false
+		}
+		item, err := mom.ModelFromBytes(v)
+		if err != nil || (q.Filter != nil && !q.Filter(item)) {
+			return false
+		}
+		// we found one more than fits, so there is another page
+		if page.Limit > 0 && len(models) == page.Limit {
+			next = last
+			return true
+		}
+		models = append(models, item)
+		last = k
+		return false
+	})
+	return models, next, nil
+}
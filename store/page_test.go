@@ -0,0 +1,70 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
+	merkle "github.com/tendermint/go-merkle"
+)
+
+func TestPagePosts(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	// two accounts, so we see the pages stay in range
+	acct := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), "Fred")
+	other := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), "Bob")
+	for i := int64(1); i <= 5; i++ {
+		_, err := mom.Save(tree, Post{Account: acct.Key(), Number: i})
+		require.Nil(err)
+		_, err = mom.Save(tree, Post{Account: other.Key(), Number: i})
+		require.Nil(err)
+	}
+	key := PostsForAccount(acct, 0)
+
+	// no limit returns all, no cursor
+	posts, next, err := PagePosts(tree, key, nil, Page{})
+	require.Nil(err)
+	assert.Equal(5, len(posts))
+	assert.Nil(next)
+
+	cases := []struct {
+		reverse bool
+		pages   [][]int64
+	}{
+		{false, [][]int64{{1, 2}, {3, 4}, {5}}},
+		{true, [][]int64{{5, 4}, {3, 2}, {1}}},
+	}
+	for _, tc := range cases {
+		page := Page{Limit: 2, Reverse: tc.reverse}
+		for i, expected := range tc.pages {
+			posts, next, err = PagePosts(tree, key, nil, page)
+			require.Nil(err)
+			if assert.Equal(len(expected), len(posts)) {
+				for j := range posts {
+					assert.Equal(acct.Key(), posts[j].Account)
+					assert.Equal(expected[j], posts[j].Number)
+				}
+			}
+			// only the last page has no cursor
+			if i == len(tc.pages)-1 {
+				assert.Nil(next)
+			} else {
+				require.NotNil(next)
+				last, err := mom.KeyToBytes(posts[len(posts)-1].Key())
+				require.Nil(err)
+				assert.Equal(last, next)
+			}
+			page.After = next
+		}
+	}
+
+	// an exact page size has no cursor at the end
+	posts, next, err = PagePosts(tree, key, nil, Page{Limit: 5})
+	require.Nil(err)
+	assert.Equal(5, len(posts))
+	assert.Nil(next)
+}
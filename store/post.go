@@ -7,13 +7,15 @@ import (
 	"github.com/tendermint/go-merkle"
 )
 
-// Post represents one verifiably immutable blog entry (so no typos ;)
+// Post represents one verifiably immutable blog entry.
+// Typos can be fixed with a PostRevision, but this original stays as it was
 type Post struct {
 	Account        mom.Key
 	Number         int64
 	PublishedBlock uint64
 	Title          string
 	Content        string
+	Parent         mom.Key // PostKey of the post this replies to, nil if none
 }
 
 // PostKey is the index of this Post structure
@@ -48,6 +50,20 @@ func PostsForAccount(acct Account, number int64) mom.Key {
 	return Post{Account: acct.Key(), Number: number}.Key()
 }
 
+// PagePosts returns one page of the posts in the range of key that pass the filter,
+// along with the cursor for the next page
+func PagePosts(store merkle.Tree, key mom.Key, filter func(mom.Model) bool, page Page) ([]Post, []byte, error) {
+	models, next, err := ListPage(store, mom.Query{Key: key, Filter: filter}, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := make([]Post, len(models))
+	for i := range models {
+		res[i] = models[i].(Post)
+	}
+	return res, next, nil
+}
+
 // ListPosts makes a search over all accounts, and casts them to the proper type
 // note an empty response returns no error
 func ListPosts(store merkle.Tree, key mom.Key, filter func(mom.Model) bool) ([]Post, error) {
@@ -65,3 +81,35 @@ func ListPosts(store merkle.Tree, key mom.Key, filter func(mom.Model) bool) ([]P
 	}
 	return res, nil
 }
+
+// PostDetails holds a post along with the latest revision and retraction (both nil if none),
+// the endorsements and the number of revisions and replies, which we need to show the current state of the post
+type PostDetails struct {
+	Post
+	Latest       *PostRevision
+	Revisions    int64
+	Retraction   *Retraction
+	Endorsements []Endorsement
+	Replies      int64
+}
+
+// LoadPostDetails looks up the latest revision, retraction, endorsements and replies for this post.
+// They are all stored under their own keys, so the signed post never changes
+func LoadPostDetails(store merkle.Tree, post Post) (PostDetails, error) {
+	var err error
+	res := PostDetails{Post: post}
+	res.Latest, err = LatestRevision(store, post)
+	if res.Latest != nil {
+		res.Revisions = res.Latest.Revision
+	}
+	if err == nil {
+		res.Retraction, err = FindRetraction(store, post.Key())
+	}
+	if err == nil {
+		res.Endorsements, err = ListEndorsements(store, post.Key())
+	}
+	if err == nil {
+		res.Replies, err = CountReplies(store, post.Key())
+	}
+	return res, err
+}
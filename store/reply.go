@@ -0,0 +1,76 @@
+package store
+
+import (
+	"math"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// Reply indexes a post under its parent, so we can list the replies to a post
+// without scanning all posts
+type Reply struct {
+	Parent mom.Key // the PostKey of the parent
+	Number int64   // 1 for the first reply to the parent, 2 for the second...
+	Post   mom.Key // the PostKey of the reply
+}
+
+// ReplyKey is the index of the Reply structure
+type ReplyKey struct {
+	Parent mom.Key
+	Number int64
+}
+
+// Key returns the index of the Reply (parent, number), so replies are ordered by time
+func (r Reply) Key() mom.Key {
+	return ReplyKey{
+		Parent: r.Parent,
+		Number: r.Number,
+	}
+}
+
+// Range contains all replies to the parent if Number is not set
+func (k ReplyKey) Range() (mom.Key, mom.Key) {
+	min, max := k, k
+	min.Parent, max.Parent = k.Parent.Range()
+
+	if k.Number == 0 {
+		min.Number = 1
+		max.Number = math.MaxInt32
+	}
+	return min, max
+}
+
+// CountReplies returns the number of replies to the parent, which is the number of the last one
+func CountReplies(store merkle.Tree, parent mom.Key) (int64, error) {
+	models, _, err := ListPage(store, mom.Query{Key: ReplyKey{Parent: parent}}, Page{Limit: 1, Reverse: true})
+	if err != nil || len(models) == 0 {
+		return 0, err
+	}
+	return models[0].(Reply).Number, nil
+}
+
+// PageReplies returns one page of the posts replying to the parent that pass the filter,
+// along with the cursor for the next page
+func PageReplies(store merkle.Tree, parent mom.Key, filter func(mom.Model) bool, page Page) ([]Post, []byte, error) {
+	query := mom.Query{Key: ReplyKey{Parent: parent}}
+	if filter != nil {
+		query.Filter = func(m mom.Model) bool {
+			post, err := mom.Load(store, m.(Reply).Post)
+			return err == nil && post != nil && filter(post)
+		}
+	}
+	models, next, err := ListPage(store, query, page)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := make([]Post, len(models))
+	for i := range models {
+		post, err := mom.Load(store, models[i].(Reply).Post)
+		if err != nil {
+			return nil, nil, err
+		}
+		res[i] = post.(Post)
+	}
+	return res, next, nil
+}
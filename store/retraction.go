@@ -0,0 +1,54 @@
+package store
+
+import (
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// Retraction is the signed tombstone an author leaves to disavow a post.
+// The post and its revisions stay in the tree untouched, so they can still be proven:
+// a post is retracted if there is a Retraction for it
+type Retraction struct {
+	Post           mom.Key // the PostKey of the retracted post
+	Reason         string
+	PublishedBlock uint64
+}
+
+// RetractionKey is the index of the Retraction structure
+type RetractionKey struct {
+	Post mom.Key
+}
+
+// Key returns the post, which can only be retracted once
+func (r Retraction) Key() mom.Key {
+	return RetractionKey{Post: r.Post}
+}
+
+// Range returns all retractions for the posts in the range of Post
+func (k RetractionKey) Range() (mom.Key, mom.Key) {
+	min, max := k, k
+	min.Post, max.Post = k.Post.Range()
+	return min, max
+}
+
+// FindRetraction returns the tombstone for this post, nil if it was not retracted
+func FindRetraction(store merkle.Tree, post mom.Key) (*Retraction, error) {
+	model, err := mom.Load(store, RetractionKey{Post: post})
+	if err != nil || model == nil {
+		return nil, err
+	}
+	res := model.(Retraction)
+	return &res, nil
+}
+
+// PostNotRetracted returns a mom.Query filter to hide retracted posts
+func PostNotRetracted(store merkle.Tree) func(mom.Model) bool {
+	return func(m mom.Model) bool {
+		post, ok := m.(Post)
+		if !ok {
+			return false
+		}
+		tomb, err := FindRetraction(store, post.Key())
+		return err == nil && tomb == nil
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"math"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// PostRevision is one edit of a Post. The original Post is never changed,
+// so the full history stays in the tree for auditing
+type PostRevision struct {
+	Post           mom.Key // the PostKey of the edited post
+	Revision       int64   // 1 for the first edit
+	PublishedBlock uint64
+	Title          string
+	Content        string
+}
+
+// PostRevisionKey is the index of this PostRevision structure
+type PostRevisionKey struct {
+	Post     mom.Key
+	Revision int64
+}
+
+// Key returns the index of the PostRevision (post, revision)
+func (r PostRevision) Key() mom.Key {
+	return PostRevisionKey{
+		Post:     r.Post,
+		Revision: r.Revision,
+	}
+}
+
+// Range contains all revisions of the post if Revision is not set
+func (k PostRevisionKey) Range() (mom.Key, mom.Key) {
+	min, max := k, k
+	min.Post, max.Post = k.Post.Range()
+
+	if k.Revision == 0 {
+		min.Revision = 1
+		max.Revision = math.MaxInt32
+	}
+	return min, max
+}
+
+// LatestRevision returns the last edit of the post, nil if it was never edited
+func LatestRevision(store merkle.Tree, post Post) (*PostRevision, error) {
+	query := mom.Query{Key: PostRevisionKey{Post: post.Key()}}
+	models, _, err := ListPage(store, query, Page{Limit: 1, Reverse: true})
+	if err != nil || len(models) == 0 {
+		return nil, err
+	}
+	res := models[0].(PostRevision)
+	return &res, nil
+}
+
+// CountRevisions returns the number of edits of the post, which is the number of the latest one
+func CountRevisions(store merkle.Tree, post Post) (int64, error) {
+	latest, err := LatestRevision(store, post)
+	if err != nil || latest == nil {
+		return 0, err
+	}
+	return latest.Revision, nil
+}
+
+// ListRevisions returns all edits of the post with this key, oldest first
+func ListRevisions(store merkle.Tree, post mom.Key) ([]PostRevision, error) {
+	models, err := mom.List(store, mom.Query{Key: PostRevisionKey{Post: post}})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]PostRevision, len(models))
+	for i := range models {
+		res[i] = models[i].(PostRevision)
+	}
+	return res, nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
+	merkle "github.com/tendermint/go-merkle"
+)
+
+func TestRevisions(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	tree := merkle.NewIAVLTree(0, nil) // in-memory
+
+	acct := NewAccount(crypto.GenPrivKeyEd25519().PubKey(), "Fred")
+	first := Post{Account: acct.Key(), Number: 1, Title: "First"}
+	second := Post{Account: acct.Key(), Number: 2, Title: "Second"}
+
+	// nothing edited yet
+	latest, err := LatestRevision(tree, first)
+	assert.Nil(err)
+	assert.Nil(latest)
+	revs, err := ListRevisions(tree, first.Key())
+	assert.Nil(err)
+	assert.Equal(0, len(revs))
+
+	// edit the first post twice, the second once
+	for i := int64(1); i <= 2; i++ {
+		_, err = mom.Save(tree, PostRevision{Post: first.Key(), Revision: i, Content: "fixed"})
+		require.Nil(err)
+	}
+	_, err = mom.Save(tree, PostRevision{Post: second.Key(), Revision: 1})
+	require.Nil(err)
+
+	// each post only sees its own revisions
+	revs, err = ListRevisions(tree, first.Key())
+	require.Nil(err)
+	if assert.Equal(2, len(revs)) {
+		assert.EqualValues(1, revs[0].Revision)
+		assert.EqualValues(2, revs[1].Revision)
+	}
+	revs, err = ListRevisions(tree, second.Key())
+	require.Nil(err)
+	assert.Equal(1, len(revs))
+
+	latest, err = LatestRevision(tree, first)
+	require.Nil(err)
+	if assert.NotNil(latest) {
+		assert.EqualValues(2, latest.Revision)
+	}
+	count, err := CountRevisions(tree, second)
+	require.Nil(err)
+	assert.EqualValues(1, count)
+}
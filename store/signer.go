@@ -0,0 +1,44 @@
+package store
+
+import (
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/tendermint/go-merkle"
+)
+
+// Signer maps the address of the key that currently controls an account to the account.
+// The account ID is the address of the first key, and never changes when the key is rotated
+type Signer struct {
+	Address []byte
+	Account mom.Key
+}
+
+// SignerKey is the index of the Signer structure
+type SignerKey struct {
+	Address []byte
+}
+
+// Key returns the address, as one key can only control one account
+func (s Signer) Key() mom.Key {
+	return SignerKey{Address: s.Address}
+}
+
+// Range only supports lookup of one address
+func (k SignerKey) Range() (mom.Key, mom.Key) {
+	return k, k
+}
+
+// IndexSigner stores the signer index for the current key of this account
+func IndexSigner(store merkle.Tree, acct Account) error {
+	_, err := mom.Save(store, Signer{Address: acct.Signer, Account: acct.Key()})
+	return err
+}
+
+// RemoveSigner deletes the signer index for this address, so the key no longer controls the account
+func RemoveSigner(store merkle.Tree, address []byte) error {
+	key, err := mom.KeyToBytes(SignerKey{Address: address})
+	if err != nil {
+		return err
+	}
+	store.Remove(key)
+	return nil
+}
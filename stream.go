@@ -0,0 +1,337 @@
+package signedpost
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ethanfrey/signedpost/store"
+	"github.com/ethanfrey/signedpost/txn"
+	"github.com/ethanfrey/signedpost/utils"
+	"github.com/ethanfrey/signedpost/view"
+	"github.com/ethanfrey/tenderize/mom"
+	"github.com/ethanfrey/tenderize/sign"
+	merkle "github.com/tendermint/go-merkle"
+)
+
+const (
+	// streamBacklog is the number of past events we keep for clients resuming with ?from= or Last-Event-ID
+	streamBacklog = 1000
+	// streamBuffer is the number of events we queue for a client, before we drop it as too slow
+	streamBuffer = 100
+	// streamPing is how often we send a comment to keep idle connections open
+	streamPing = 30 * time.Second
+)
+
+// txEvent is what we remember about a successful tx, until the block is committed and we can render it
+type txEvent struct {
+	action  string
+	account []byte // id of the account that made the change
+	result  []byte // the key returned by the tx
+}
+
+// actionName is the event type for each action
+func actionName(action sign.Action) string {
+	switch a := action.(type) {
+	case txn.CreateAccountAction:
+		return "account"
+	case txn.AddPostAction:
+		return "post"
+	case txn.EditPostAction:
+		return "edit"
+	case txn.RetractPostAction:
+		return "retract"
+	case txn.NotarizeAction:
+		return "notarize"
+	case txn.RotateKeyAction:
+		return "rotate"
+	case txn.CreateMultisigAction:
+		return "multisig"
+	case txn.MultisigAction:
+		// we show what the multisig account did
+		inner, err := a.GetAction()
+		if err != nil {
+			return "multisig"
+		}
+		return actionName(inner)
+	case txn.EndorsePostAction:
+		return "endorse"
+	case txn.AttestIdentityAction:
+		return "attest"
+	case txn.RevokeAttestationAction:
+		return "revoke"
+	case txn.UpdateProfileAction:
+		return "profile"
+	case txn.FollowAction:
+		return "follow"
+	case txn.UnfollowAction:
+		return "unfollow"
+	}
+	return "unknown"
+}
+
+// newTxEvent finds the account that made the change, right after the tx was applied to the tree
+func newTxEvent(tree merkle.Tree, tx sign.ValidatedAction, result []byte) txEvent {
+	res := txEvent{action: actionName(tx.GetAction()), result: result}
+	if key, err := mom.KeyFromBytes(result); err == nil {
+		if acct, ok := key.(store.AccountKey); ok {
+			res.account = acct.ID
+			return res
+		}
+	}
+	switch action := tx.GetAction().(type) {
+	case txn.MultisigAction:
+		res.account = action.Account
+	case txn.RotateKeyAction:
+		res.account = signerAccountID(tree, action.NewKey.Address())
+	default:
+		if tx.GetSigner() != nil {
+			res.account = signerAccountID(tree, tx.GetSigner().Address())
+		}
+	}
+	return res
+}
+
+// signerAccountID returns the id of the account controlled by this address, nil if none
+func signerAccountID(tree merkle.Tree, addr []byte) []byte {
+	model, err := mom.Load(tree, store.SignerKey{Address: addr})
+	if err != nil || model == nil {
+		return nil
+	}
+	if acct, ok := model.(store.Signer).Account.(store.AccountKey); ok {
+		return acct.ID
+	}
+	return nil
+}
+
+// streamFilter selects the events a client wants
+type streamFilter struct {
+	account string          // only events by or about this account id (as rendered), all if empty
+	actions map[string]bool // only events of these types, all if empty
+}
+
+func (f streamFilter) matches(ev *view.Event) bool {
+	if len(f.actions) > 0 && !f.actions[ev.Type] {
+		return false
+	}
+	if f.account == "" {
+		return true
+	}
+	return (ev.Account != nil && ev.Account.ID == f.account) ||
+		(ev.Post != nil && ev.Post.AccountID == f.account)
+}
+
+// streamPos is where a client resumes: it gets all events after this one
+type streamPos struct {
+	height uint64 // 0 to only get new events
+	index  int    // -1 to get the whole block
+}
+
+// before is true if the event comes after the position
+func (p streamPos) before(ev *view.Event) bool {
+	return ev.Height > p.height || (ev.Height == p.height && ev.Index > p.index)
+}
+
+// parseEventID reads the position from an event id, as sent back in Last-Event-ID
+func parseEventID(id string) (streamPos, error) {
+	parts := strings.Split(id, "-")
+	if len(parts) != 2 {
+		return streamPos{}, utils.BadRequest(errors.New("Invalid event id, must be height-index"))
+	}
+	height, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return streamPos{}, errors.Wrap(err, "Invalid event id")
+	}
+	index, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return streamPos{}, errors.Wrap(err, "Invalid event id")
+	}
+	return streamPos{height: height, index: index}, nil
+}
+
+type subscriber struct {
+	filter streamFilter
+	events chan *view.Event
+}
+
+// stream pushes the events of every commit to all subscribers, and keeps a backlog for
+// clients that reconnect. It never blocks the commit: clients that fall behind are dropped
+type stream struct {
+	mtx     sync.Mutex
+	backlog []*view.Event
+	first   uint64      // the first block we published, we never saw the events before it
+	pruned  *view.Event // the newest event that was dropped from the backlog
+	subs    map[*subscriber]bool
+}
+
+// newStream creates a stream that publishes the events from the block at this height on
+func newStream(first uint64) *stream {
+	return &stream{first: first, subs: map[*subscriber]bool{}}
+}
+
+// publish sends the events of one block to all subscribers
+func (s *stream) publish(events []*view.Event) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.backlog = append(s.backlog, events...)
+	if drop := len(s.backlog) - streamBacklog; drop > 0 {
+		s.pruned = s.backlog[drop-1]
+		s.backlog = append([]*view.Event{}, s.backlog[drop:]...)
+	}
+
+	for sub := range s.subs {
+		for _, ev := range events {
+			if !sub.filter.matches(ev) {
+				continue
+			}
+			select {
+			case sub.events <- ev:
+			default:
+				// too slow, it can reconnect with Last-Event-ID
+				delete(s.subs, sub)
+				close(sub.events)
+			}
+			if !s.subs[sub] {
+				break
+			}
+		}
+	}
+}
+
+// subscribe registers a new subscriber, and returns the events after this position (if its height
+// is not 0) that it missed. If the backlog no longer reaches back to it, this returns an error
+func (s *stream) subscribe(filter streamFilter, from streamPos) (*subscriber, []*view.Event, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// the events of earlier blocks were published before we (re)started
+	if from.height > 0 && from.height < s.first {
+		return nil, nil, utils.BadRequest(errors.Errorf("Events before height %d are no longer available", s.first))
+	}
+	if from.height > 0 && s.pruned != nil && from.before(s.pruned) {
+		return nil, nil, utils.BadRequest(errors.Errorf("Events up to %d-%d are no longer available", s.pruned.Height, s.pruned.Index))
+	}
+	var missed []*view.Event
+	for _, ev := range s.backlog {
+		if from.height > 0 && from.before(ev) && filter.matches(ev) {
+			missed = append(missed, ev)
+		}
+	}
+	sub := &subscriber{filter: filter, events: make(chan *view.Event, streamBuffer)}
+	s.subs[sub] = true
+	return sub, missed, nil
+}
+
+// unsubscribe removes the subscriber, if it was not dropped already
+func (s *stream) unsubscribe(sub *subscriber) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.subs[sub] {
+		delete(s.subs, sub)
+		close(sub.events)
+	}
+}
+
+// renderEvents renders the txs of the last block against the new snapshot
+func (app *Application) renderEvents(snap view.Snapshot) []*view.Event {
+	res := make([]*view.Event, 0, len(app.pending))
+	for _, tx := range app.pending {
+		ev, err := view.TxEvent(snap, tx.action, tx.account, tx.result)
+		// this only fails on a broken store, which the queries will report
+		if err == nil {
+			ev.Index = len(res)
+			res = append(res, ev)
+		}
+	}
+	app.pending = nil
+	return res
+}
+
+// queryStreamFilter parses the ?account=, ?type= and ?from= params of /stream.
+// The Last-Event-ID header of a reconnecting client takes precedence over ?from=
+func queryStreamFilter(r *http.Request) (streamFilter, streamPos, error) {
+	q := r.URL.Query()
+	filter := streamFilter{account: q.Get("account")}
+	if t := q.Get("type"); t != "" {
+		filter.actions = map[string]bool{}
+		for _, action := range strings.Split(t, ",") {
+			filter.actions[action] = true
+		}
+	}
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		from, err := parseEventID(id)
+		return filter, from, err
+	}
+	from := streamPos{index: -1}
+	if f := q.Get("from"); f != "" {
+		var err error
+		from.height, err = strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return filter, from, errors.Wrap(err, "Invalid from")
+		}
+	}
+	return filter, from, nil
+}
+
+// Stream sends the events of every commit as server-sent events, until the client disconnects
+func (app *Application) Stream(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		utils.RenderQuery(rw, nil, utils.NewError(utils.CodeInternal, "Streaming not supported", nil))
+		return
+	}
+	filter, from, err := queryStreamFilter(r)
+	var sub *subscriber
+	var missed []*view.Event
+	if err == nil {
+		sub, missed, err = app.stream.subscribe(filter, from)
+	}
+	if err != nil {
+		utils.RenderQuery(rw, nil, err)
+		return
+	}
+	defer app.stream.unsubscribe(sub)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	for _, ev := range missed {
+		writeEvent(rw, ev)
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			writeEvent(rw, ev)
+		case <-ping.C:
+			fmt.Fprint(rw, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes one server-sent event, with the height and index as id, so clients can resume
+// right after it
+func writeEvent(rw http.ResponseWriter, ev *view.Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(rw, "id: %d-%d\nevent: %s\ndata: %s\n\n", ev.Height, ev.Index, ev.Type, data)
+}
@@ -0,0 +1,151 @@
+package signedpost
+
+import (
+	"encoding/hex"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/tendermint/tendermint/types"
+	tmsp "github.com/tendermint/tmsp/types"
+
+	"github.com/ethanfrey/signedpost/redux"
+	"github.com/ethanfrey/signedpost/utils"
+)
+
+// txIndexSize is the number of txs whose status we remember
+const txIndexSize = 10000
+
+// The status of a tx, as reported by /tndr/tx/{hash}
+const (
+	TxPending   = "pending"   // passed CheckTx, but is not yet in a committed block
+	TxCommitted = "committed" // in a committed block, and applied to the state
+	TxRejected  = "rejected"  // refused by CheckTx, or by AppendTx (then it has a height)
+	TxUnknown   = "unknown"   // not tracked by this node, it may still be in a block
+)
+
+// TxStatus is what we know about a tx
+type TxStatus struct {
+	Hash     string        `json:"hash"`
+	Status   string        `json:"status"`
+	Height   uint64        `json:"height,omitempty"` // block the tx was included in
+	Code     tmsp.CodeType `json:"code,omitempty"`
+	CodeName string        `json:"code_name,omitempty"`
+	Log      string        `json:"log,omitempty"`
+	Data     []byte        `json:"data,omitempty"` // the key returned by the tx
+}
+
+// TxHash returns the hash tendermint uses to identify this tx
+func TxHash(tx []byte) []byte {
+	return types.Tx(tx).Hash()
+}
+
+// txIndex remembers the result of the last txs we saw in CheckTx and AppendTx.
+// It only lives in memory, so it does not know about txs from before a restart
+type txIndex struct {
+	mtx       sync.RWMutex
+	txs       map[string]*TxStatus
+	order     []string // hashes in the order we saw them, to forget the oldest
+	committed uint64   // last committed height
+}
+
+func newTxIndex() *txIndex {
+	return &txIndex{txs: map[string]*TxStatus{}}
+}
+
+// check records the result of CheckTx, unless the tx is already in a block
+func (x *txIndex) check(tx []byte, res tmsp.Result) {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+
+	st := x.find(tx)
+	if st.Height > 0 {
+		return
+	}
+	st.Status, st.Code, st.Log = TxPending, res.Code, res.Log
+	if res.IsErr() {
+		st.Status = TxRejected
+	}
+}
+
+// append records the result of AppendTx in the block at this height
+func (x *txIndex) append(tx []byte, height uint64, res tmsp.Result) {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+
+	st := x.find(tx)
+	st.Status, st.Height, st.Code, st.Log, st.Data = TxPending, height, res.Code, res.Log, res.Data
+	if res.IsErr() {
+		st.Status = TxRejected
+	}
+}
+
+// commit marks all txs up to this height as committed
+func (x *txIndex) commit(height uint64) {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+	x.committed = height
+}
+
+// find returns the status of the tx, adding it (and forgetting the oldest) if it is new.
+// The caller must hold the lock
+func (x *txIndex) find(tx []byte) *TxStatus {
+	key := string(TxHash(tx))
+	if st, ok := x.txs[key]; ok {
+		return st
+	}
+	if len(x.order) >= txIndexSize {
+		delete(x.txs, x.order[0])
+		x.order = x.order[1:]
+	}
+	st := &TxStatus{Hash: hex.EncodeToString([]byte(key))}
+	x.txs[key] = st
+	x.order = append(x.order, key)
+	return st
+}
+
+// get returns a copy of the status of the tx with this hash, nil if we never saw it
+func (x *txIndex) get(hash []byte) *TxStatus {
+	x.mtx.RLock()
+	defer x.mtx.RUnlock()
+
+	st, ok := x.txs[string(hash)]
+	if !ok {
+		return nil
+	}
+	res := *st
+	if res.Status == TxPending && res.Height > 0 && res.Height <= x.committed {
+		res.Status = TxCommitted
+	}
+	if ec, ok := redux.LookupErrorCode(res.Code); ok {
+		res.CodeName = ec.Name
+	} else if res.Code != tmsp.CodeType_OK {
+		res.CodeName = res.Code.String()
+	}
+	return &res
+}
+
+// TxStatus returns the status of a tx by its hash. This is best-effort: we only know the
+// txs this node saw since it started (and only the last txIndexSize of them), anything else
+// is unknown rather than rejected, as it may have been committed all the same
+func (app *Application) TxStatus(hash []byte) (*TxStatus, error) {
+	st := app.txs.get(hash)
+	if st == nil {
+		st = &TxStatus{
+			Hash:   hex.EncodeToString(hash),
+			Status: TxUnknown,
+			Log:    "Not tracked by this node, it was never sent here or is too old",
+		}
+	}
+	return st, nil
+}
+
+// GetTxStatus reports if the tx is pending, committed (at which height) or rejected (and why)
+func (app *Application) GetTxStatus(rw http.ResponseWriter, r *http.Request) {
+	var st *TxStatus
+	hash, err := hex.DecodeString(mux.Vars(r)["hash"])
+	if err == nil {
+		st, err = app.TxStatus(hash)
+	}
+	utils.RenderQuery(rw, st, err)
+}
@@ -1,6 +1,7 @@
 package txn
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,7 +47,7 @@ func TestSignSerialization(t *testing.T) {
 
 	wire, err := signed.Serialize()
 	require.Nil(err, "%+v", err)
-	require.Equal(129, len(wire))
+	require.Equal(138, len(wire))
 
 	// make sure the data is there
 	parsed, err := sign.Receive(wire)
@@ -67,3 +68,98 @@ func TestSignSerialization(t *testing.T) {
 	require.Nil(err, "%+v", err)
 	assert.Equal(wire, wire3)
 }
+
+func TestRotateKeySerialization(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	priv := crypto.GenPrivKeyEd25519()
+	newKey := crypto.GenPrivKeyEd25519()
+	account := priv.PubKey().Address()
+
+	tx := RotateKeyAction{Sequence: 7}
+	require.Nil(tx.CounterSign(account, newKey))
+	assert.Nil(tx.ValidateCounterSignature(account))
+	assert.NotNil(tx.ValidateCounterSignature(newKey.PubKey().Address()))
+
+	// the keys survive the round trip
+	data, err := sign.Send(tx, priv)
+	require.Nil(err, "%+v", err)
+	validated, err := sign.Receive(data)
+	require.Nil(err, "%+v", err)
+	parsed, ok := validated.GetAction().(RotateKeyAction)
+	require.True(ok)
+	assert.True(newKey.PubKey().Equals(parsed.NewKey))
+	assert.Nil(parsed.ValidateCounterSignature(account))
+}
+
+func TestMultisigSerialization(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+	submitter := crypto.GenPrivKeyEd25519()
+	members := []crypto.PrivKey{crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()}
+	account := []byte("12345678901234567890")
+
+	tx, err := NewMultisigAction(account, AddPostAction{Title: "Joint", Sequence: 3})
+	require.Nil(err, "%+v", err)
+	for _, m := range members {
+		require.Nil(tx.AddSignature(m))
+	}
+
+	// the signatures and wrapped action survive the round trip
+	data, err := sign.Send(tx, submitter)
+	require.Nil(err, "%+v", err)
+	validated, err := sign.Receive(data)
+	require.Nil(err, "%+v", err)
+	parsed, ok := validated.GetAction().(MultisigAction)
+	require.True(ok)
+	signers, err := parsed.Signers()
+	require.Nil(err, "%+v", err)
+	if assert.Equal(2, len(signers)) {
+		assert.Equal(members[0].PubKey().Address(), signers[0])
+		assert.Equal(members[1].PubKey().Address(), signers[1])
+	}
+	inner, err := parsed.GetAction()
+	require.Nil(err, "%+v", err)
+	post, ok := inner.(AddPostAction)
+	if assert.True(ok) {
+		assert.Equal("Joint", post.Title)
+		assert.EqualValues(3, post.Sequence)
+	}
+
+	// validate the definition
+	create := CreateMultisigAction{Name: "Joint", Threshold: 2, Members: signers}
+	assert.Nil(create.ValidateMembers())
+	create.Threshold = 0
+	assert.NotNil(create.ValidateMembers())
+	create = CreateMultisigAction{Name: "Joint", Threshold: 1, Members: [][]byte{signers[0], signers[0]}}
+	assert.NotNil(create.ValidateMembers())
+	create = CreateMultisigAction{Name: "Joint", Threshold: 1, Members: [][]byte{[]byte("short")}}
+	assert.NotNil(create.ValidateMembers())
+}
+
+func TestValidateProfile(t *testing.T) {
+	assert := assert.New(t)
+	avatar := make([]byte, 32)
+
+	cases := []struct {
+		profile UpdateProfileAction
+		valid   bool
+	}{
+		{UpdateProfileAction{}, true},
+		{UpdateProfileAction{DisplayName: "Alice W.", Bio: "Über alles", Website: "https://alice.example.com/blog", AvatarHash: avatar}, true},
+		{UpdateProfileAction{DisplayName: "Alice\nW."}, false},
+		{UpdateProfileAction{DisplayName: strings.Repeat("a", MaxDisplayNameLength+1)}, false},
+		{UpdateProfileAction{Bio: strings.Repeat("a", MaxBioLength+1)}, false},
+		{UpdateProfileAction{Bio: string([]byte{0xff, 0xfe})}, false},
+		{UpdateProfileAction{Website: "alice.example.com"}, false},
+		{UpdateProfileAction{Website: "ftp://alice.example.com"}, false},
+		{UpdateProfileAction{AvatarHash: avatar[:20]}, false},
+	}
+
+	for i, tc := range cases {
+		err := tc.profile.ValidateProfile()
+		if tc.valid {
+			assert.Nil(err, "%d: %+v", i, err)
+		} else {
+			assert.NotNil(err, "%d", i)
+		}
+	}
+}
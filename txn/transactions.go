@@ -1,9 +1,33 @@
 package txn
 
-import "github.com/ethanfrey/tenderize/sign"
+import (
+	"net/url"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ethanfrey/tenderize/sign"
+	wutil "github.com/ethanfrey/tenderize/wire"
+	"github.com/pkg/errors"
+	crypto "github.com/tendermint/go-crypto"
+)
 
 func init() {
-	sign.RegisterActions(CreateAccountAction{}, AddPostAction{})
+	sign.RegisterActions(CreateAccountAction{}, AddPostAction{}, NotarizeAction{}, EditPostAction{}, RetractPostAction{}, RotateKeyAction{},
+		CreateMultisigAction{}, MultisigAction{}, EndorsePostAction{},
+		AttestIdentityAction{}, RevokeAttestationAction{}, UpdateProfileAction{},
+		FollowAction{}, UnfollowAction{})
+}
+
+// Supported hash algorithms for NotarizeAction
+const (
+	HashSHA256 = "sha256"
+	HashSHA512 = "sha512"
+)
+
+// digestSizes is the expected length of a digest for each supported algorithm
+var digestSizes = map[string]int{
+	HashSHA256: 32,
+	HashSHA512: 64,
 }
 
 // CreateAccountAction is used once to claim a username for a given public key
@@ -16,13 +40,398 @@ func (c CreateAccountAction) IsAction() error {
 	return nil
 }
 
+// MaxNameLength is the longest username (in bytes) we accept
+const MaxNameLength = 32
+
+// ValidateName makes sure the username is not empty, not too long, and has no control characters
+func (c CreateAccountAction) ValidateName() error {
+	return validateName(c.Name)
+}
+
+func validateName(name string) error {
+	if len(name) == 0 || len(name) > MaxNameLength {
+		return errors.Errorf("Name must be 1 to %d bytes", MaxNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return errors.New("Name may not contain control characters")
+		}
+	}
+	return nil
+}
+
 // AddPostAction is used for an existing account to append an entry to its list
 type AddPostAction struct {
-	Title   string
-	Content string
+	Title    string
+	Content  string
+	Parent   []byte // optional key of the post this replies to
+	Sequence int64  // must be one more than the last sequence of the account
 }
 
 // IsAction fulfills interface for go-wire
 func (c AddPostAction) IsAction() error {
 	return nil
 }
+
+// EditPostAction adds a new revision to a post of the signing account.
+// The original post and all earlier revisions are kept
+type EditPostAction struct {
+	Number   int64 // number of the post in the account
+	Title    string
+	Content  string
+	Sequence int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c EditPostAction) IsAction() error {
+	return nil
+}
+
+// RetractPostAction disavows a post of the signing account, leaving a tombstone with the reason.
+// The post itself is kept, and can no longer be edited
+type RetractPostAction struct {
+	Number   int64 // number of the post in the account
+	Reason   string
+	Sequence int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c RetractPostAction) IsAction() error {
+	return nil
+}
+
+// RotateKeyAction moves control of the signing account to NewKey.
+// The account id and all posts stay the same, only the key that may sign for it changes.
+// The new key must countersign, to prove it is held by the owner
+type RotateKeyAction struct {
+	NewKey           crypto.PubKey
+	CounterSignature crypto.Signature // by NewKey over CounterSignBytes
+	Sequence         int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c RotateKeyAction) IsAction() error {
+	return nil
+}
+
+// rotateKeyMsg is what the new key signs, so the countersignature is only valid for this account and sequence
+type rotateKeyMsg struct {
+	Account  []byte
+	NewKey   crypto.PubKey
+	Sequence int64
+}
+
+// CounterSignBytes returns the bytes the new key must sign to join this account (by id)
+func (c RotateKeyAction) CounterSignBytes(account []byte) ([]byte, error) {
+	return wutil.ToBinary(rotateKeyMsg{
+		Account:  account,
+		NewKey:   c.NewKey,
+		Sequence: c.Sequence,
+	})
+}
+
+// CounterSign adds the signature of the new key, which must match NewKey
+func (c *RotateKeyAction) CounterSign(account []byte, newKey crypto.PrivKey) error {
+	c.NewKey = newKey.PubKey()
+	msg, err := c.CounterSignBytes(account)
+	if err != nil {
+		return err
+	}
+	c.CounterSignature = newKey.Sign(msg)
+	return nil
+}
+
+// ValidateCounterSignature makes sure NewKey signed off on controlling this account
+func (c RotateKeyAction) ValidateCounterSignature(account []byte) error {
+	if c.NewKey == nil || c.CounterSignature == nil {
+		return errors.New("Missing new key or countersignature")
+	}
+	msg, err := c.CounterSignBytes(account)
+	if err != nil {
+		return err
+	}
+	if !c.NewKey.VerifyBytes(msg, c.CounterSignature) {
+		return errors.New("Invalid countersignature")
+	}
+	return nil
+}
+
+// MaxMultisigMembers limits the size of a multisig account (and the work to check it)
+const MaxMultisigMembers = 16
+
+// addressLength is the size of the address of a public key
+const addressLength = 20
+
+// CreateMultisigAction creates an account that needs Threshold of the Members to sign
+// for it (with a MultisigAction). The signer must be one of the members
+type CreateMultisigAction struct {
+	Name      string
+	Threshold int64
+	Members   [][]byte // addresses of the member keys
+}
+
+// IsAction fulfills interface for go-wire
+func (c CreateMultisigAction) IsAction() error {
+	return nil
+}
+
+// ValidateName makes sure the username is not empty, not too long, and has no control characters
+func (c CreateMultisigAction) ValidateName() error {
+	return validateName(c.Name)
+}
+
+// ValidateMembers makes sure we have a reachable threshold of distinct member addresses
+func (c CreateMultisigAction) ValidateMembers() error {
+	if len(c.Members) == 0 || len(c.Members) > MaxMultisigMembers {
+		return errors.Errorf("Must have 1 to %d members", MaxMultisigMembers)
+	}
+	if c.Threshold < 1 || c.Threshold > int64(len(c.Members)) {
+		return errors.Errorf("Threshold must be 1 to %d", len(c.Members))
+	}
+	seen := map[string]bool{}
+	for _, m := range c.Members {
+		if len(m) != addressLength {
+			return errors.Errorf("Member address must be %d bytes", addressLength)
+		}
+		if seen[string(m)] {
+			return errors.New("Duplicate member")
+		}
+		seen[string(m)] = true
+	}
+	return nil
+}
+
+// MemberSignature is the signature of one member of a multisig account
+type MemberSignature struct {
+	PubKey    crypto.PubKey
+	Signature crypto.Signature
+}
+
+// MultisigAction wraps an action to run for a multisig account, along with the signatures
+// of the members over SignBytes. The signer of the tx itself can be anyone (eg. the last member)
+type MultisigAction struct {
+	Account    []byte // id of the multisig account
+	Action     []byte // the wrapped action, as encoded by sign.ActionToBytes
+	Signatures []MemberSignature
+}
+
+// IsAction fulfills interface for go-wire
+func (c MultisigAction) IsAction() error {
+	return nil
+}
+
+// NewMultisigAction wraps the action for this account, without any signatures yet
+func NewMultisigAction(account []byte, action sign.Action) (MultisigAction, error) {
+	data, err := sign.ActionToBytes(action)
+	return MultisigAction{Account: account, Action: data}, err
+}
+
+// multisigMsg is what the members sign, so the signatures are only valid for this account
+type multisigMsg struct {
+	Account []byte
+	Action  []byte
+}
+
+// SignBytes returns the bytes every member must sign
+func (c MultisigAction) SignBytes() ([]byte, error) {
+	return wutil.ToBinary(multisigMsg{Account: c.Account, Action: c.Action})
+}
+
+// AddSignature signs the wrapped action with the key of one member
+func (c *MultisigAction) AddSignature(key crypto.PrivKey) error {
+	msg, err := c.SignBytes()
+	if err != nil {
+		return err
+	}
+	c.Signatures = append(c.Signatures, MemberSignature{
+		PubKey:    key.PubKey(),
+		Signature: key.Sign(msg),
+	})
+	return nil
+}
+
+// Signers verifies all signatures, and returns the addresses of the distinct keys that signed
+func (c MultisigAction) Signers() ([][]byte, error) {
+	msg, err := c.SignBytes()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	res := [][]byte{}
+	for _, sig := range c.Signatures {
+		if sig.PubKey == nil || sig.Signature == nil || !sig.PubKey.VerifyBytes(msg, sig.Signature) {
+			return nil, errors.New("Invalid member signature")
+		}
+		addr := sig.PubKey.Address()
+		if !seen[string(addr)] {
+			seen[string(addr)] = true
+			res = append(res, addr)
+		}
+	}
+	return res, nil
+}
+
+// GetAction decodes the wrapped action
+func (c MultisigAction) GetAction() (sign.Action, error) {
+	return sign.ActionFromBytes(c.Action)
+}
+
+// EndorsePostAction attaches the signature of the signing account to the post of another account
+type EndorsePostAction struct {
+	Post     []byte // the PostKey, as rendered in the post id
+	Comment  string // optional
+	Sequence int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c EndorsePostAction) IsAction() error {
+	return nil
+}
+
+// Limits on the size of an attestation
+const (
+	MaxClaimLength = 32
+	MaxValueLength = 256
+)
+
+// AttestIdentityAction lets the signing account (the attester) make a claim about the
+// real-world identity of the subject account. Attesting the same claim again replaces it
+type AttestIdentityAction struct {
+	Subject      []byte // id of the subject account
+	Claim        string // type of the claim, eg. "legal_name" or "email_sha256"
+	Value        string
+	ExpiresBlock uint64 // the claim is no longer valid from this height, 0 for never
+	Sequence     int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c AttestIdentityAction) IsAction() error {
+	return nil
+}
+
+// ValidateClaim makes sure the claim has a type and fits the limits
+func (c AttestIdentityAction) ValidateClaim() error {
+	if len(c.Claim) == 0 || len(c.Claim) > MaxClaimLength {
+		return errors.Errorf("Claim must be 1 to %d bytes", MaxClaimLength)
+	}
+	if len(c.Value) > MaxValueLength {
+		return errors.Errorf("Value may be at most %d bytes", MaxValueLength)
+	}
+	return nil
+}
+
+// RevokeAttestationAction revokes a claim the signing account made about the subject
+type RevokeAttestationAction struct {
+	Subject  []byte // id of the subject account
+	Claim    string
+	Sequence int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c RevokeAttestationAction) IsAction() error {
+	return nil
+}
+
+// Limits on the size of the profile fields
+const (
+	MaxDisplayNameLength = 64
+	MaxBioLength         = 1024
+	MaxWebsiteLength     = 256
+)
+
+// UpdateProfileAction replaces the profile of the signing account.
+// Every field is optional, and an empty field clears it
+type UpdateProfileAction struct {
+	DisplayName string // shown instead of the username, need not be unique
+	Bio         string
+	Website     string // absolute http(s) url
+	AvatarHash  []byte // sha256 of the avatar image, which is stored elsewhere
+	Sequence    int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c UpdateProfileAction) IsAction() error {
+	return nil
+}
+
+// ValidateProfile checks the size and format of every field
+func (c UpdateProfileAction) ValidateProfile() error {
+	if len(c.DisplayName) > MaxDisplayNameLength {
+		return errors.Errorf("Display name may be at most %d bytes", MaxDisplayNameLength)
+	}
+	for _, r := range c.DisplayName {
+		if unicode.IsControl(r) {
+			return errors.New("Display name may not contain control characters")
+		}
+	}
+	if len(c.Bio) > MaxBioLength {
+		return errors.Errorf("Bio may be at most %d bytes", MaxBioLength)
+	}
+	if !utf8.ValidString(c.Bio) {
+		return errors.New("Bio must be valid utf-8")
+	}
+	if c.Website != "" {
+		if len(c.Website) > MaxWebsiteLength {
+			return errors.Errorf("Website may be at most %d bytes", MaxWebsiteLength)
+		}
+		u, err := url.Parse(c.Website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("Website must be an absolute http(s) url")
+		}
+	}
+	if len(c.AvatarHash) != 0 && len(c.AvatarHash) != digestSizes[HashSHA256] {
+		return errors.Errorf("Avatar hash must be a %d byte sha256", digestSizes[HashSHA256])
+	}
+	return nil
+}
+
+// MaxFollowing is the most accounts one account can follow, which bounds the cost of building its feed
+const MaxFollowing = 1000
+
+// FollowAction adds the posts of another account to the feed of the signing account
+type FollowAction struct {
+	Account  []byte // id of the account to follow
+	Sequence int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c FollowAction) IsAction() error {
+	return nil
+}
+
+// UnfollowAction removes an account from the feed of the signing account
+type UnfollowAction struct {
+	Account  []byte // id of the followed account
+	Sequence int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c UnfollowAction) IsAction() error {
+	return nil
+}
+
+// NotarizeAction is used for an existing account to prove the existence of a document
+// at this time, without publishing its content
+type NotarizeAction struct {
+	Algorithm string
+	Digest    []byte
+	Title     string // optional
+	Sequence  int64
+}
+
+// IsAction fulfills interface for go-wire
+func (c NotarizeAction) IsAction() error {
+	return nil
+}
+
+// ValidateDigest makes sure we know the algorithm and the digest has the proper length
+func (c NotarizeAction) ValidateDigest() error {
+	size, ok := digestSizes[c.Algorithm]
+	if !ok {
+		return errors.Errorf("Unknown hash algorithm: %s", c.Algorithm)
+	}
+	if len(c.Digest) != size {
+		return errors.Errorf("Digest for %s must be %d bytes", c.Algorithm, size)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Error codes in the json body of a failed request, each with its own http status
+const (
+	CodeBadRequest = "bad_request" // 400: malformed input (bad hex, params out of range...)
+	CodeNotFound   = "not_found"   // 404: no model with this key
+	CodeRejected   = "rejected"    // 422: the signature or the transaction was rejected
+	CodeInternal   = "internal"    // 500: something broke on our side
+	CodeChain      = "chain_error" // 502: tendermint core failed or is unreachable
+)
+
+// statuses is the http status for each error code
+var statuses = map[string]int{
+	CodeBadRequest: http.StatusBadRequest,
+	CodeNotFound:   http.StatusNotFound,
+	CodeRejected:   http.StatusUnprocessableEntity,
+	CodeInternal:   http.StatusInternalServerError,
+	CodeChain:      http.StatusBadGateway,
+}
+
+// Error is a failure we report to the client as json, with the http status for its code.
+// Any other error is reported as internal, unless it comes from parsing the input (see ToError)
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Error fulfills the error interface
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Status returns the http status for the error code
+func (e Error) Status() int {
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorResponse is the json body of every failed request
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// NewError creates an error with this code and message
+func NewError(code, msg string, details interface{}) error {
+	return Error{Code: code, Message: msg, Details: details}
+}
+
+// BadRequest marks err as caused by malformed input, eg. a bad id or a param out of range
+func BadRequest(err error) error {
+	return NewError(CodeBadRequest, err.Error(), nil)
+}
+
+// ErrNotFound is returned when there is no model with the requested key
+func ErrNotFound() error {
+	return NewError(CodeNotFound, "Not Found", nil)
+}
+
+// ErrRejected is returned when we refuse a transaction, eg. for a bad signature
+func ErrRejected(msg string, details interface{}) error {
+	return NewError(CodeRejected, msg, details)
+}
+
+// ErrChain wraps a failure of tendermint core, so we report it as a bad gateway
+func ErrChain(err error) error {
+	return NewError(CodeChain, err.Error(), nil)
+}
+
+// ToError finds the Error behind err (also if it was wrapped). Failures to parse hex,
+// numbers or json come from the input, so they are a bad request, anything else is internal
+func ToError(err error) Error {
+	switch cause := errors.Cause(err).(type) {
+	case Error:
+		// keep the context of the wrapped message
+		cause.Message = err.Error()
+		return cause
+	case hex.InvalidByteError, *strconv.NumError, *json.SyntaxError, *json.UnmarshalTypeError:
+		return Error{Code: CodeBadRequest, Message: err.Error()}
+	}
+	if errors.Cause(err) == hex.ErrLength {
+		return Error{Code: CodeBadRequest, Message: err.Error()}
+	}
+	return Error{Code: CodeInternal, Message: err.Error()}
+}
@@ -2,7 +2,7 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
+	"encoding/xml"
 	"net/http"
 )
 
@@ -11,12 +11,41 @@ func RenderQuery(rw http.ResponseWriter, res interface{}, err error) {
 	var data []byte
 	if err == nil {
 		data, err = json.Marshal(res)
+		if err != nil {
+			err = NewError(CodeInternal, err.Error(), nil)
+		}
 	}
 	if err != nil {
-		rw.WriteHeader(400)
-		rw.Write([]byte(fmt.Sprintf("%v", err)))
+		RenderError(rw, err)
 		return
 	}
 	rw.Header().Set("Content-Type", "application/json")
 	rw.Write(data)
 }
+
+// RenderXML writes the result as xml with the given content type, or the error as RenderQuery does
+func RenderXML(rw http.ResponseWriter, contentType string, res interface{}, err error) {
+	var data []byte
+	if err == nil {
+		data, err = xml.Marshal(res)
+		if err != nil {
+			err = NewError(CodeInternal, err.Error(), nil)
+		}
+	}
+	if err != nil {
+		RenderError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", contentType)
+	rw.Write([]byte(xml.Header))
+	rw.Write(data)
+}
+
+// RenderError writes the error as a json ErrorResponse, with the http status for its code
+func RenderError(rw http.ResponseWriter, err error) {
+	res := ErrorResponse{Error: ToError(err)}
+	data, _ := json.Marshal(res)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(res.Error.Status())
+	rw.Write(data)
+}
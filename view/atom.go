@@ -0,0 +1,186 @@
+package view
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/ethanfrey/signedpost/store"
+	"github.com/ethanfrey/signedpost/utils"
+	"github.com/ethanfrey/tenderize/mom"
+)
+
+const (
+	// AtomEntries is the number of (newest) posts in an atom feed
+	AtomEntries = 50
+	// atomNS is the namespace of our extensions to atom, for the block height
+	atomNS = "https://github.com/ethanfrey/signedpost"
+)
+
+// AtomFeed is an atom (RFC 4287) feed of posts, for ordinary feed readers
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	NS      string      `xml:"xmlns:sp,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []AtomLink  `xml:"link"`
+	Height  uint64      `xml:"sp:height"` // height of the commit we read from
+	Entries []AtomEntry `xml:"entry"`
+}
+
+// AtomLink points to the html or json representation of a feed or entry
+type AtomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+// AtomEntry is one post in the feed
+type AtomEntry struct {
+	ID             string     `xml:"id"` // derived from the post key, so it never changes
+	Title          string     `xml:"title"`
+	Updated        string     `xml:"updated"`
+	Published      string     `xml:"published"`
+	Author         AtomAuthor `xml:"author"`
+	Links          []AtomLink `xml:"link"`
+	Content        string     `xml:"content"`
+	PublishedBlock uint64     `xml:"sp:published_block"`
+	EditedBlock    uint64     `xml:"sp:edited_block,omitempty"`
+	Retracted      bool       `xml:"sp:retracted,omitempty"`
+}
+
+// AtomAuthor is the account that signed the post
+type AtomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+// atomTime renders a time for atom, which requires one in every feed and entry.
+// Entries have the time of the block they were published (or edited) in, see BlockTimes.
+// If we do not know it, we use the fallback: when this server committed the state we read from,
+// which is the updated time of the feed
+func atomTime(t, fallback time.Time) string {
+	if t.IsZero() {
+		t = fallback
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// AccountAtom returns the newest posts of this account as an atom feed, without the retracted ones
+// if hideRetracted is set. base is the url of the server, to make absolute links
+func AccountAtom(snap Snapshot, key []byte, base string, hideRetracted bool) (*AtomFeed, error) {
+	acctKey, err := parseAccountKey(key)
+	if err != nil {
+		return nil, err
+	}
+	model, err := mom.Load(snap.Tree, acctKey)
+	if err != nil {
+		return nil, err
+	}
+	if model == nil {
+		return nil, utils.ErrNotFound()
+	}
+	acct := RenderAccount(model.(store.Account))
+	posts, _, err := store.PagePosts(snap.Tree, store.PostKey{Account: acctKey}, retractedFilter(snap, hideRetracted),
+		store.Page{Limit: AtomEntries, Reverse: true})
+	if err != nil {
+		return nil, err
+	}
+	details, err := loadDetails(snap, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	title := acct.Name
+	if acct.DisplayName != "" {
+		title = acct.DisplayName
+	}
+	res := renderAtom(snap, details, map[string]*Account{acct.ID: acct}, base)
+	res.ID = "urn:signedpost:account:" + acct.ID
+	res.Title = title + " - Signed Post"
+	res.Links = []AtomLink{
+		{Rel: "self", Type: "application/atom+xml", Href: base + "/accounts/" + acct.ID + "/feed.atom"},
+		{Rel: "alternate", Type: "application/json", Href: base + "/accounts/" + acct.ID + "/posts"},
+	}
+	return res, nil
+}
+
+// GlobalAtom returns the newest posts of all accounts as an atom feed, without the retracted ones
+// if hideRetracted is set. base is the url of the server, to make absolute links
+func GlobalAtom(snap Snapshot, base string, hideRetracted bool) (*AtomFeed, error) {
+	accts, err := store.ListAccounts(snap.Tree, nil)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]mom.Key, len(accts))
+	for i := range accts {
+		keys[i] = accts[i].Key()
+	}
+	posts, _, err := store.PageNewest(snap.Tree, keys, retractedFilter(snap, hideRetracted), store.Page{Limit: AtomEntries})
+	if err != nil {
+		return nil, err
+	}
+	details, err := loadDetails(snap, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	// look up the author of each post once
+	authors := map[string]*Account{}
+	for _, post := range posts {
+		model, err := mom.Load(snap.Tree, post.Account)
+		if err != nil {
+			return nil, err
+		}
+		if model != nil {
+			acct := RenderAccount(model.(store.Account))
+			authors[acct.ID] = acct
+		}
+	}
+
+	res := renderAtom(snap, details, authors, base)
+	res.ID = "urn:signedpost:posts"
+	res.Title = "Signed Post"
+	res.Links = []AtomLink{
+		{Rel: "self", Type: "application/atom+xml", Href: base + "/feed.atom"},
+	}
+	return res, nil
+}
+
+// renderAtom renders the posts (newest first) as entries, the caller must fill in the feed info
+func renderAtom(snap Snapshot, posts []store.PostDetails, authors map[string]*Account, base string) *AtomFeed {
+	res := &AtomFeed{
+		NS:      atomNS,
+		Updated: atomTime(snap.Time, snap.Time),
+		Height:  snap.Height,
+		Entries: make([]AtomEntry, len(posts)),
+	}
+	for i := range posts {
+		post := RenderPost(posts[i], snap.Times)
+		published := blockTime(snap.Times, post.PublishedBlock)
+		updated := published
+		if post.EditedBlock > 0 {
+			updated = blockTime(snap.Times, post.EditedBlock)
+		}
+		entry := AtomEntry{
+			ID:             "urn:signedpost:post:" + post.ID,
+			Title:          post.Title,
+			Published:      atomTime(published, snap.Time),
+			Updated:        atomTime(updated, snap.Time),
+			Content:        post.Content,
+			PublishedBlock: post.PublishedBlock,
+			EditedBlock:    post.EditedBlock,
+			Retracted:      post.Retracted,
+			Author:         AtomAuthor{Name: post.AccountID, URI: base + "/accounts/" + post.AccountID},
+			Links: []AtomLink{
+				{Rel: "alternate", Type: "application/json", Href: base + "/posts/" + post.ID},
+				{Rel: "related", Type: "application/json", Href: base + "/posts/" + post.ID + "/proof"},
+			},
+		}
+		if author := authors[post.AccountID]; author != nil {
+			entry.Author.Name = author.Name
+		}
+		res.Entries[i] = entry
+	}
+	return res
+}
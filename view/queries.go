@@ -1,65 +1,489 @@
 package view
 
 import (
+	"encoding/hex"
+
 	"github.com/pkg/errors"
 
 	"github.com/ethanfrey/signedpost/store"
+	"github.com/ethanfrey/signedpost/utils"
 	"github.com/ethanfrey/tenderize/mom"
 	merkle "github.com/tendermint/go-merkle"
 )
 
-// AllAccounts returns what you expect
-func AllAccounts(tree merkle.Tree) (*AccountList, error) {
-	accts, err := store.ListAccounts(tree, nil)
+// parseAccountKey accepts an account id as we render it (the go-wire key), nothing else
+func parseAccountKey(id []byte) (store.AccountKey, error) {
+	key, err := mom.KeyFromBytes(id)
+	if err != nil {
+		return store.AccountKey{}, utils.BadRequest(errors.Wrap(err, "Invalid account id"))
+	}
+	acct, ok := key.(store.AccountKey)
+	if !ok {
+		return store.AccountKey{}, utils.BadRequest(errors.New("Not an account id"))
+	}
+	return acct, nil
+}
+
+// parsePostKey accepts a post id as we render it (the go-wire key), nothing else
+func parsePostKey(id []byte) (store.PostKey, error) {
+	key, err := mom.KeyFromBytes(id)
+	if err != nil {
+		return store.PostKey{}, utils.BadRequest(errors.Wrap(err, "Invalid post id"))
+	}
+	post, ok := key.(store.PostKey)
+	if !ok {
+		return store.PostKey{}, utils.BadRequest(errors.New("Not a post id"))
+	}
+	return post, nil
+}
+
+// AllAccounts returns one page of all accounts, ordered by id
+func AllAccounts(snap Snapshot, page store.Page) (*AccountList, error) {
+	accts, next, err := store.PageAccounts(snap.Tree, page)
 	if err != nil {
 		return nil, err
 	}
-	return RenderAccountList(accts), nil
+	res := RenderAccountList(accts)
+	res.Next = renderCursor(next)
+	res.Height = snap.Height
+	return res, nil
 }
 
-// AccountByKey returns an exact match
-func AccountByKey(tree merkle.Tree, key []byte) (*Account, error) {
-	model, err := mom.Load(tree, store.AccountKey{ID: key})
+// AccountByKey returns an exact match, along with the valid attestations
+func AccountByKey(snap Snapshot, key []byte, trusted TrustedAttesters) (*Account, error) {
+	acctKey, err := parseAccountKey(key)
+	if err != nil {
+		return nil, err
+	}
+	model, err := mom.Load(snap.Tree, acctKey)
 	if err != nil {
 		return nil, err
 	}
 	if model == nil {
-		return nil, errors.New("Not Found")
+		return nil, utils.ErrNotFound()
+	}
+	return accountDetails(snap, model.(store.Account), trusted)
+}
+
+// CheckAccount returns an error unless key is a valid account id of an existing account
+func CheckAccount(snap Snapshot, key []byte) error {
+	acctKey, err := parseAccountKey(key)
+	if err != nil {
+		return err
+	}
+	model, err := mom.Load(snap.Tree, acctKey)
+	if err == nil && model == nil {
+		err = utils.ErrNotFound()
+	}
+	return err
+}
+
+// accountDetails renders the account with the valid attestations about it
+func accountDetails(snap Snapshot, acct store.Account, trusted TrustedAttesters) (*Account, error) {
+	atts, err := store.ListAttestations(snap.Tree, acct.Key())
+	if err != nil {
+		return nil, err
 	}
-	return RenderAccount(model.(store.Account)), nil
+	res := RenderAccount(acct)
+	for _, att := range atts {
+		if att.IsValid(snap.Height) {
+			res.Attestations = append(res.Attestations, RenderAttestation(att, snap.Height, trusted))
+		}
+	}
+	res.Height = snap.Height
+	return res, nil
 }
 
-// AccountByName searches for similar names
-func AccountByName(tree merkle.Tree, name string) (*AccountList, error) {
-	accts, err := store.ListAccounts(tree, store.AccountContainsName(name))
+// AccountAttestations returns all attestations about the account, including revoked and expired ones
+func AccountAttestations(snap Snapshot, key []byte, trusted TrustedAttesters) (*AttestationList, error) {
+	acctKey, err := parseAccountKey(key)
 	if err != nil {
 		return nil, err
 	}
-	return RenderAccountList(accts), nil
+	atts, err := store.ListAttestations(snap.Tree, acctKey)
+	if err != nil {
+		return nil, err
+	}
+	res := &AttestationList{
+		Items:  make([]*Attestation, len(atts)),
+		Count:  int64(len(atts)),
+		Height: snap.Height,
+	}
+	for i, att := range atts {
+		res.Items[i] = RenderAttestation(att, snap.Height, trusted)
+	}
+	return res, nil
 }
 
-// PostsForAccount returns all posts that belong to this account
-func PostsForAccount(tree merkle.Tree, acct []byte) (*PostList, error) {
-	key := store.PostKey{Account: store.AccountKey{ID: acct}}
-	posts, err := store.ListPosts(tree, key, nil)
+// AccountBySigner returns the account currently controlled by the key with this address
+func AccountBySigner(snap Snapshot, addr []byte, trusted TrustedAttesters) (*Account, error) {
+	model, err := mom.Load(snap.Tree, store.SignerKey{Address: addr})
 	if err != nil {
 		return nil, err
 	}
-	return RenderPostList(posts), nil
+	if model == nil {
+		return nil, utils.ErrNotFound()
+	}
+	model, err = mom.Load(snap.Tree, model.(store.Signer).Account)
+	if err != nil {
+		return nil, err
+	}
+	if model == nil {
+		return nil, utils.ErrNotFound()
+	}
+	return accountDetails(snap, model.(store.Account), trusted)
+}
+
+// MultisigForAccount returns the threshold and members of a multisig account
+func MultisigForAccount(snap Snapshot, key []byte) (*Multisig, error) {
+	acctKey, err := parseAccountKey(key)
+	if err != nil {
+		return nil, err
+	}
+	def, err := store.FindMultisig(snap.Tree, acctKey)
+	if err != nil {
+		return nil, err
+	}
+	if def == nil {
+		return nil, utils.ErrNotFound()
+	}
+	res := RenderMultisig(*def)
+	res.Height = snap.Height
+	return res, nil
+}
+
+// AccountByName searches for all names starting with this prefix (ignoring case)
+func AccountByName(snap Snapshot, name string) (*AccountList, error) {
+	accts, err := store.SearchAccounts(snap.Tree, name)
+	if err != nil {
+		return nil, err
+	}
+	res := RenderAccountList(accts)
+	res.Height = snap.Height
+	return res, nil
+}
+
+// retractedFilter returns the filter to leave out retracted posts if hide is set, nil to show all
+func retractedFilter(snap Snapshot, hide bool) func(mom.Model) bool {
+	if !hide {
+		return nil
+	}
+	return store.PostNotRetracted(snap.Tree)
+}
+
+// PostsForAccount returns one page of the posts that belong to this account, ordered by number.
+// Retracted posts are flagged, or left out if hideRetracted is set
+func PostsForAccount(snap Snapshot, acct []byte, page store.Page, hideRetracted bool) (*PostList, error) {
+	filter := retractedFilter(snap, hideRetracted)
+	acctKey, err := parseAccountKey(acct)
+	if err != nil {
+		return nil, err
+	}
+	key := store.PostKey{Account: acctKey}
+	posts, next, err := store.PagePosts(snap.Tree, key, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	details, err := loadDetails(snap, posts)
+	if err != nil {
+		return nil, err
+	}
+	res := RenderPostList(details, snap.Times)
+	res.Next = renderCursor(next)
+	res.Height = snap.Height
+	return res, nil
+}
+
+// AccountFeed returns one page of the posts of all accounts this account follows, newest first.
+// Retracted posts are flagged, or left out if hideRetracted is set
+func AccountFeed(snap Snapshot, acct []byte, page store.Page, hideRetracted bool) (*PostList, error) {
+	filter := retractedFilter(snap, hideRetracted)
+	acctKey, err := parseAccountKey(acct)
+	if err != nil {
+		return nil, err
+	}
+	posts, next, err := store.PageFeed(snap.Tree, acctKey, filter, page)
+	if errors.Cause(err) == store.ErrInvalidCursor {
+		return nil, utils.BadRequest(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	details, err := loadDetails(snap, posts)
+	if err != nil {
+		return nil, err
+	}
+	res := RenderPostList(details, snap.Times)
+	res.Next = renderCursor(next)
+	res.Height = snap.Height
+	return res, nil
 }
 
 // PostByKey returns an exact match
-func PostByKey(tree merkle.Tree, key []byte) (*Post, error) {
-	postKey, err := mom.KeyFromBytes(key)
+func PostByKey(snap Snapshot, key []byte) (*Post, error) {
+	post, err := loadPost(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	details, err := store.LoadPostDetails(snap.Tree, post)
+	if err != nil {
+		return nil, err
+	}
+	res := RenderPost(details, snap.Times)
+	res.Height = snap.Height
+	return res, nil
+}
+
+// loadPost makes sure the key is for an existing post
+func loadPost(snap Snapshot, key []byte) (store.Post, error) {
+	postKey, err := parsePostKey(key)
+	if err != nil {
+		return store.Post{}, err
+	}
+	model, err := mom.Load(snap.Tree, postKey)
+	if err != nil {
+		return store.Post{}, err
+	}
+	if model == nil {
+		return store.Post{}, utils.ErrNotFound()
+	}
+	return model.(store.Post), nil
+}
+
+// PostRevisions returns the original post and all edits
+func PostRevisions(snap Snapshot, key []byte) (*RevisionList, error) {
+	post, err := loadPost(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	revs, err := store.ListRevisions(snap.Tree, post.Key())
+	if err != nil {
+		return nil, err
+	}
+	res := RenderRevisions(post, revs, snap.Times)
+	res.Height = snap.Height
+	return res, nil
+}
+
+// PostReplies returns one page of the direct replies to a post, oldest first.
+// Retracted replies are flagged, or left out if hideRetracted is set
+func PostReplies(snap Snapshot, key []byte, page store.Page, hideRetracted bool) (*PostList, error) {
+	post, err := loadPost(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	replies, next, err := store.PageReplies(snap.Tree, post.Key(), retractedFilter(snap, hideRetracted), page)
 	if err != nil {
 		return nil, err
 	}
-	posts, err := store.ListPosts(tree, postKey, nil)
+	details, err := loadDetails(snap, replies)
+	if err != nil {
+		return nil, err
+	}
+	res := RenderPostList(details, snap.Times)
+	res.Next = renderCursor(next)
+	res.Height = snap.Height
+	return res, nil
+}
+
+// PostThread returns the post with the tree of replies below it, down to depth levels.
+// Each post shows at most limit replies (0 for all), the replies count tells if there are more.
+// The whole tree has at most budget posts, filled level by level: a post whose replies
+// were cut off by the budget is marked truncated, and can be loaded as its own thread.
+// Retracted replies (and the replies below them) are left out if hideRetracted is set
+func PostThread(snap Snapshot, key []byte, depth, limit, budget int, hideRetracted bool) (*Thread, error) {
+	post, err := loadPost(snap, key)
+	if err != nil {
+		return nil, err
+	}
+	res, err := threadNode(snap, post)
+	if err != nil {
+		return nil, err
+	}
+	res.Height = snap.Height
+	budget--
+	filter := retractedFilter(snap, hideRetracted)
+
+	type pending struct {
+		node  *Thread
+		post  store.Post
+		depth int
+	}
+	queue := []pending{{res, post, depth}}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if next.depth <= 0 || next.node.Replies == 0 {
+			continue
+		}
+		if budget <= 0 {
+			next.node.Truncated = true
+			continue
+		}
+		page := store.Page{Limit: limit}
+		if limit == 0 || limit > budget {
+			page.Limit = budget
+		}
+		replies, more, err := store.PageReplies(snap.Tree, next.post.Key(), filter, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, reply := range replies {
+			child, err := threadNode(snap, reply)
+			if err != nil {
+				return nil, err
+			}
+			next.node.Children = append(next.node.Children, child)
+			queue = append(queue, pending{child, reply, next.depth - 1})
+		}
+		budget -= len(replies)
+		// the replies count tells if limit left some out, we only flag those the budget left out
+		if more != nil && page.Limit != limit {
+			next.node.Truncated = true
+		}
+	}
+	return res, nil
+}
+
+func threadNode(snap Snapshot, post store.Post) (*Thread, error) {
+	details, err := store.LoadPostDetails(snap.Tree, post)
+	if err != nil {
+		return nil, err
+	}
+	return &Thread{Post: RenderPost(details, snap.Times)}, nil
+}
+
+// PostEndorsements returns all endorsements of the post, each with a merkle proof
+func PostEndorsements(snap Snapshot, key []byte) (*EndorsementList, error) {
+	post, err := loadPost(snap, key)
 	if err != nil {
 		return nil, err
 	}
-	if len(posts) == 0 {
-		return nil, errors.New("Not Found")
+	ends, err := store.ListEndorsements(snap.Tree, post.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	res := &EndorsementList{
+		Post:   hex.EncodeToString(key),
+		Items:  make([]*Endorsement, len(ends)),
+		Count:  int64(len(ends)),
+		Height: snap.Height,
+	}
+	for i, end := range ends {
+		res.Items[i] = RenderEndorsement(end, snap.Times)
+		res.Items[i].Proof, err = ProveKey(snap, end.Key())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// TxEvent renders the event for a tx of the last block, after the block was committed.
+// account is the id of the account that made the change, result the key returned by the tx
+func TxEvent(snap Snapshot, action string, account, result []byte) (*Event, error) {
+	res := &Event{Height: snap.Height, Type: action}
+	model, err := mom.Load(snap.Tree, store.AccountKey{ID: account})
+	if err != nil {
+		return nil, err
+	}
+	if model != nil {
+		res.Account = RenderAccount(model.(store.Account))
+	}
+
+	// include the post if the tx changed one
+	var postKey mom.Key
+	if key, err := mom.KeyFromBytes(result); err == nil {
+		switch k := key.(type) {
+		case store.PostKey:
+			postKey = k
+		case store.PostRevisionKey:
+			postKey = k.Post
+		case store.RetractionKey:
+			postKey = k.Post
+		case store.EndorsementKey:
+			postKey = k.Post
+		}
+	}
+	if postKey != nil {
+		model, err = mom.Load(snap.Tree, postKey)
+		if err != nil {
+			return nil, err
+		}
+		if model != nil {
+			details, err := store.LoadPostDetails(snap.Tree, model.(store.Post))
+			if err != nil {
+				return nil, err
+			}
+			res.Post = RenderPost(details, snap.Times)
+		}
+	}
+	return res, nil
+}
+
+// loadDetails loads the latest revision, retraction and endorsements for each post
+func loadDetails(snap Snapshot, posts []store.Post) ([]store.PostDetails, error) {
+	res := make([]store.PostDetails, len(posts))
+	for i := range posts {
+		details, err := store.LoadPostDetails(snap.Tree, posts[i])
+		if err != nil {
+			return nil, err
+		}
+		res[i] = details
+	}
+	return res, nil
+}
+
+// NotaryByDigest returns when and by whom this digest was notarized
+func NotaryByDigest(snap Snapshot, digest []byte) (*Notary, error) {
+	notary, err := store.FindNotary(snap.Tree, digest)
+	if err != nil {
+		return nil, err
+	}
+	if notary == nil {
+		return nil, utils.ErrNotFound()
+	}
+	res := RenderNotary(*notary)
+	res.Height = snap.Height
+	return res, nil
+}
+
+// AccountProof returns a merkle proof for the account with this id
+func AccountProof(snap Snapshot, key []byte) (*Proof, error) {
+	acctKey, err := parseAccountKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return ProveKey(snap, acctKey)
+}
+
+// PostProof returns a merkle proof for the post with this key
+func PostProof(snap Snapshot, key []byte) (*Proof, error) {
+	postKey, err := parsePostKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return ProveKey(snap, postKey)
+}
+
+// ProveKey constructs a merkle proof for the model stored under this key
+func ProveKey(snap Snapshot, key mom.Key) (*Proof, error) {
+	iavl, ok := snap.Tree.(*merkle.IAVLTree)
+	if !ok {
+		return nil, utils.NewError(utils.CodeInternal, "Proofs require an IAVLTree", nil)
+	}
+	k, err := mom.KeyToBytes(key)
+	if err != nil {
+		return nil, err
+	}
+	_, value, exists := iavl.Get(k)
+	if !exists {
+		return nil, utils.ErrNotFound()
+	}
+	proof := iavl.ConstructProof(k)
+	if proof == nil {
+		return nil, utils.ErrNotFound()
 	}
-	return RenderPost(posts[0]), nil
+	return RenderProof(k, value, proof, snap.Height)
 }
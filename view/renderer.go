@@ -2,12 +2,17 @@ package view
 
 import (
 	"encoding/hex"
+	"time"
 
 	"github.com/ethanfrey/signedpost/store"
 	"github.com/ethanfrey/tenderize/mom"
+	wutil "github.com/ethanfrey/tenderize/wire"
+	merkle "github.com/tendermint/go-merkle"
 )
 
-func RenderPost(post store.Post) *Post {
+// RenderPost shows the post with the title and content of the latest revision,
+// and flags it if it was retracted. times may be nil if we cannot tell when it was published
+func RenderPost(post store.PostDetails, times BlockTimes) *Post {
 	// acct, err := store.AccountKeyFromPost(post.ID)
 	// if err != nil {
 	// 	panic(err)
@@ -22,23 +27,132 @@ func RenderPost(post store.Post) *Post {
 		panic(err)
 	}
 
-	return &Post{
+	res := &Post{
 		ID:             hex.EncodeToString(pKey),
 		AccountID:      hex.EncodeToString(aKey),
 		Number:         post.Number,
 		PublishedBlock: post.PublishedBlock,
+		PublishedTime:  renderTime(times, post.PublishedBlock),
 		Title:          post.Title,
 		Content:        post.Content,
+		Revisions:      post.Revisions,
+		Replies:        post.Replies,
 	}
+	if post.Parent != nil {
+		parent, err := mom.KeyToBytes(post.Parent)
+		if err != nil {
+			panic(err)
+		}
+		res.Parent = hex.EncodeToString(parent)
+	}
+	if latest := post.Latest; latest != nil {
+		res.Title = latest.Title
+		res.Content = latest.Content
+		res.EditedBlock = latest.PublishedBlock
+		res.EditedTime = renderTime(times, latest.PublishedBlock)
+	}
+	if tomb := post.Retraction; tomb != nil {
+		res.Retracted = true
+		res.RetractedBlock = tomb.PublishedBlock
+		res.RetractReason = tomb.Reason
+	}
+	for _, end := range post.Endorsements {
+		eKey, err := mom.KeyToBytes(end.Account)
+		if err != nil {
+			panic(err)
+		}
+		res.Endorsers = append(res.Endorsers, hex.EncodeToString(eKey))
+	}
+	return res
+}
+
+// RenderEndorsement shows one endorsement (without the proof)
+func RenderEndorsement(end store.Endorsement, times BlockTimes) *Endorsement {
+	pKey, err := mom.KeyToBytes(end.Post)
+	if err != nil {
+		panic(err)
+	}
+	aKey, err := mom.KeyToBytes(end.Account)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Endorsement{
+		Post:           hex.EncodeToString(pKey),
+		AccountID:      hex.EncodeToString(aKey),
+		Revision:       end.Revision,
+		Comment:        end.Comment,
+		PublishedBlock: end.PublishedBlock,
+		PublishedTime:  renderTime(times, end.PublishedBlock),
+	}
+}
+
+// RenderRevisions lists the original post as revision 0, followed by all edits
+func RenderRevisions(post store.Post, revs []store.PostRevision, times BlockTimes) *RevisionList {
+	pKey, err := mom.KeyToBytes(post.Key())
+	if err != nil {
+		panic(err)
+	}
+	id := hex.EncodeToString(pKey)
+
+	res := RevisionList{
+		Post:  id,
+		Count: int64(len(revs) + 1),
+		Items: make([]*Revision, 0, len(revs)+1),
+	}
+	res.Items = append(res.Items, &Revision{
+		Post:           id,
+		Revision:       0,
+		PublishedBlock: post.PublishedBlock,
+		PublishedTime:  renderTime(times, post.PublishedBlock),
+		Title:          post.Title,
+		Content:        post.Content,
+	})
+	for _, rev := range revs {
+		res.Items = append(res.Items, &Revision{
+			Post:           id,
+			Revision:       rev.Revision,
+			PublishedBlock: rev.PublishedBlock,
+			PublishedTime:  renderTime(times, rev.PublishedBlock),
+			Title:          rev.Title,
+			Content:        rev.Content,
+		})
+	}
+	return &res
+}
+
+// blockTime returns the time of the block, zero if unknown
+func blockTime(times BlockTimes, height uint64) time.Time {
+	if times == nil || height == 0 {
+		return time.Time{}
+	}
+	return times.BlockTime(height)
+}
+
+// renderTime formats the time of the block for json, or empty string if unknown
+func renderTime(times BlockTimes, height uint64) string {
+	t := blockTime(times, height)
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
 }
 
-func RenderPostList(posts []store.Post) *PostList {
+// renderCursor encodes the key to continue a listing from, empty if there is no more
+func renderCursor(key []byte) string {
+	if key == nil {
+		return ""
+	}
+	return hex.EncodeToString(key)
+}
+
+func RenderPostList(posts []store.PostDetails, times BlockTimes) *PostList {
 	res := PostList{
 		Count: int64(len(posts)),
 		Items: make([]*Post, len(posts)),
 	}
 	for i := range posts {
-		res.Items[i] = RenderPost(posts[i])
+		res.Items[i] = RenderPost(posts[i], times)
 	}
 	return &res
 }
@@ -53,6 +167,15 @@ func RenderAccount(acct store.Account) *Account {
 		ID:        hex.EncodeToString(aKey),
 		Name:      acct.Name,
 		PostCount: acct.EntryCount,
+		Followers: acct.Followers,
+		Following: acct.Following,
+		Sequence:  acct.Sequence,
+		Signer:    hex.EncodeToString(acct.Signer),
+
+		DisplayName: acct.Profile.DisplayName,
+		Bio:         acct.Profile.Bio,
+		Website:     acct.Profile.Website,
+		AvatarHash:  hex.EncodeToString(acct.Profile.AvatarHash),
 	}
 }
 
@@ -66,3 +189,75 @@ func RenderAccountList(accts []store.Account) *AccountList {
 	}
 	return &res
 }
+
+// RenderAttestation checks if the attestation is valid at this height,
+// and if the attester is trusted
+func RenderAttestation(att store.Attestation, height uint64, trusted TrustedAttesters) *Attestation {
+	sKey, err := mom.KeyToBytes(att.Subject)
+	if err != nil {
+		panic(err)
+	}
+	aKey, err := mom.KeyToBytes(att.Attester)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Attestation{
+		Subject:      hex.EncodeToString(sKey),
+		Attester:     hex.EncodeToString(aKey),
+		Claim:        att.Claim,
+		Value:        att.Value,
+		IssuedBlock:  att.IssuedBlock,
+		ExpiresBlock: att.ExpiresBlock,
+		Revoked:      att.Revoked,
+		RevokedBlock: att.RevokedBlock,
+		Valid:        att.IsValid(height),
+		Trusted:      trusted.Trusts(att.Attester),
+	}
+}
+
+func RenderMultisig(def store.Multisig) *Multisig {
+	aKey, err := mom.KeyToBytes(def.Account)
+	if err != nil {
+		panic(err)
+	}
+
+	res := &Multisig{
+		AccountID: hex.EncodeToString(aKey),
+		Threshold: def.Threshold,
+		Members:   make([]string, len(def.Members)),
+	}
+	for i, m := range def.Members {
+		res.Members[i] = hex.EncodeToString(m)
+	}
+	return res
+}
+
+func RenderNotary(notary store.Notary) *Notary {
+	aKey, err := mom.KeyToBytes(notary.Account)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Notary{
+		Digest:         hex.EncodeToString(notary.Digest),
+		Algorithm:      notary.Algorithm,
+		Title:          notary.Title,
+		AccountID:      hex.EncodeToString(aKey),
+		PublishedBlock: notary.PublishedBlock,
+	}
+}
+
+func RenderProof(key, value []byte, proof *merkle.IAVLProof, height uint64) (*Proof, error) {
+	pBytes, err := wutil.ToBinary(*proof)
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{
+		Key:      hex.EncodeToString(key),
+		Value:    hex.EncodeToString(value),
+		Proof:    hex.EncodeToString(pBytes),
+		RootHash: hex.EncodeToString(proof.RootHash),
+		Height:   height,
+	}, nil
+}
@@ -5,26 +5,157 @@ type Account struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
 	PostCount int64  `json:"posts"`
+	Followers int64  `json:"followers"`
+	Following int64  `json:"following"`
+	Sequence  int64  `json:"sequence"`
+	Signer    string `json:"signer"` // address of the key that controls the account now
+	// the optional profile, set with UpdateProfileAction
+	DisplayName string `json:"display_name,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	Website     string `json:"website,omitempty"`
+	AvatarHash  string `json:"avatar_hash,omitempty"` // hex sha256 of the image
+	// valid (not revoked or expired) attestations, only set when querying one account
+	Attestations []*Attestation `json:"attestations,omitempty"`
+	Height       uint64         `json:"height,omitempty"` // height of the commit we read from
 }
 
 // AccountList represent a list of accounts (from a search)
 type AccountList struct {
-	Items []*Account `json:"items"`
-	Count int64      `json:"count"`
+	Items  []*Account `json:"items"`
+	Count  int64      `json:"count"`
+	Next   string     `json:"next,omitempty"` // pass as ?after= to get the next page
+	Height uint64     `json:"height"`
 }
 
 // Post is the json object we return for one post
 type Post struct {
-	ID             string `json:"id"`
+	ID             string   `json:"id"`
+	AccountID      string   `json:"account"`
+	Number         int64    `json:"number"`
+	PublishedBlock uint64   `json:"published_block"`
+	PublishedTime  string   `json:"published_time,omitempty"` // RFC3339, time of the block if known
+	Title          string   `json:"title"`                    // from the latest revision
+	Content        string   `json:"content"`                  // from the latest revision
+	Revisions      int64    `json:"revisions"`
+	EditedBlock    uint64   `json:"edited_block,omitempty"`
+	EditedTime     string   `json:"edited_time,omitempty"`
+	Retracted      bool     `json:"retracted"`
+	RetractedBlock uint64   `json:"retracted_block,omitempty"`
+	RetractReason  string   `json:"retract_reason,omitempty"`
+	Endorsers      []string `json:"endorsers,omitempty"` // ids of the endorsing accounts
+	Parent         string   `json:"parent,omitempty"`    // id of the post this replies to
+	Replies        int64    `json:"replies"`
+	Height         uint64   `json:"height,omitempty"`
+}
+
+// Thread is a post along with the tree of replies to it
+type Thread struct {
+	*Post
+	Children  []*Thread `json:"children,omitempty"`  // the first replies, oldest first
+	Truncated bool      `json:"truncated,omitempty"` // replies left out to stay in the node budget
+}
+
+// Endorsement is the signature of another account on a post, with a proof
+// that it is in the app state
+type Endorsement struct {
+	Post           string `json:"post"`
 	AccountID      string `json:"account"`
-	Number         int64  `json:"number"`
+	Revision       int64  `json:"revision"` // the revision of the post that was endorsed
+	Comment        string `json:"comment,omitempty"`
 	PublishedBlock uint64 `json:"published_block"`
+	PublishedTime  string `json:"published_time,omitempty"`
+	Proof          *Proof `json:"proof"`
+}
+
+// Event is pushed to /stream clients for every tx in a committed block
+type Event struct {
+	Height  uint64   `json:"height"`
+	Index   int      `json:"index"`          // position among the events of this block
+	Type    string   `json:"type"`           // the action, eg. "post" or "follow"
+	Account *Account `json:"account"`        // the account that made the change
+	Post    *Post    `json:"post,omitempty"` // the post that was added or changed, if any
+}
+
+// EndorsementList is all endorsements of one post
+type EndorsementList struct {
+	Post   string         `json:"post"`
+	Items  []*Endorsement `json:"items"`
+	Count  int64          `json:"count"`
+	Height uint64         `json:"height"`
+}
+
+// Revision is one version of a post, revision 0 is the original
+type Revision struct {
+	Post           string `json:"post"`
+	Revision       int64  `json:"revision"`
+	PublishedBlock uint64 `json:"published_block"`
+	PublishedTime  string `json:"published_time,omitempty"`
 	Title          string `json:"title"`
 	Content        string `json:"content"`
 }
 
+// RevisionList is the full history of one post, oldest first
+type RevisionList struct {
+	Post   string      `json:"post"`
+	Items  []*Revision `json:"items"`
+	Count  int64       `json:"count"`
+	Height uint64      `json:"height"`
+}
+
 // PostList represent a list of posts (for a user)
 type PostList struct {
-	Items []*Post `json:"items"`
-	Count int64   `json:"count"`
+	Items  []*Post `json:"items"`
+	Count  int64   `json:"count"`
+	Next   string  `json:"next,omitempty"` // pass as ?after= to get the next page
+	Height uint64  `json:"height"`
+}
+
+// Attestation is a claim of the attester about the real-world identity of the subject account
+type Attestation struct {
+	Subject      string `json:"subject"`
+	Attester     string `json:"attester"`
+	Claim        string `json:"claim"`
+	Value        string `json:"value"`
+	IssuedBlock  uint64 `json:"issued_block"`
+	ExpiresBlock uint64 `json:"expires_block,omitempty"`
+	Revoked      bool   `json:"revoked"`
+	RevokedBlock uint64 `json:"revoked_block,omitempty"`
+	Valid        bool   `json:"valid"`   // not revoked or expired at the queried height
+	Trusted      bool   `json:"trusted"` // the attester is trusted by this server
+}
+
+// AttestationList is all attestations about one account
+type AttestationList struct {
+	Items  []*Attestation `json:"items"`
+	Count  int64          `json:"count"`
+	Height uint64         `json:"height"`
+}
+
+// Multisig is the json object for the definition of a multisig account
+type Multisig struct {
+	AccountID string   `json:"account"`
+	Threshold int64    `json:"threshold"`
+	Members   []string `json:"members"` // hex addresses of the member keys
+	Height    uint64   `json:"height"`
+}
+
+// Notary is the json object we return for one notarized digest
+type Notary struct {
+	Digest         string `json:"digest"`
+	Algorithm      string `json:"algorithm"`
+	Title          string `json:"title"`
+	AccountID      string `json:"account"`
+	PublishedBlock uint64 `json:"published_block"`
+	Height         uint64 `json:"height"`
+}
+
+// Proof contains everything needed to verify one model against the app hash of a block,
+// without trusting this server. All binary data is hex-encoded go-wire.
+// The app hash after block Height is stored in the header of block Height+1
+type Proof struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Proof    string `json:"proof"`
+	RootHash string `json:"root_hash"`
+	Height   uint64 `json:"height"`
 }
@@ -0,0 +1,24 @@
+package view
+
+import (
+	"time"
+
+	merkle "github.com/tendermint/go-merkle"
+)
+
+// Snapshot is a read-only copy of the state as of one commit.
+// All queries run against a Snapshot, so they never see a half-applied block,
+// and can run in any goroutine while the app keeps modifying its own tree.
+type Snapshot struct {
+	Tree   merkle.Tree
+	Height uint64
+	Hash   []byte
+	Time   time.Time  // when this server committed it (not consensus data), zero if unknown
+	Times  BlockTimes // to render when posts were published, nil if unknown
+}
+
+// BlockTimes finds the time of the block at a height, zero if unknown.
+// This is not in the consensus state (TMSP only passes us the height), so it is only used to render
+type BlockTimes interface {
+	BlockTime(height uint64) time.Time
+}
@@ -0,0 +1,30 @@
+package view
+
+import (
+	"github.com/ethanfrey/signedpost/store"
+	"github.com/ethanfrey/tenderize/mom"
+)
+
+// TrustedAttesters is the set of attester accounts this server trusts.
+// This is local configuration, not part of the app state, so it only
+// flags attestations in the responses and never changes the consensus
+type TrustedAttesters map[string]bool
+
+// NewTrustedAttesters accepts account ids as we render them
+func NewTrustedAttesters(ids [][]byte) (TrustedAttesters, error) {
+	res := TrustedAttesters{}
+	for _, id := range ids {
+		key, err := parseAccountKey(id)
+		if err != nil {
+			return nil, err
+		}
+		res[string(key.ID)] = true
+	}
+	return res, nil
+}
+
+// Trusts checks if the account with this key is a trusted attester
+func (t TrustedAttesters) Trusts(key mom.Key) bool {
+	acct, ok := key.(store.AccountKey)
+	return ok && t[string(acct.ID)]
+}